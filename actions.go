@@ -0,0 +1,117 @@
+package mutagenmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mutagen-io/mutagen/pkg/grpcutil"
+	"github.com/mutagen-io/mutagen/pkg/selection"
+	servicePrompting "github.com/mutagen-io/mutagen/pkg/service/prompting"
+	serviceSync "github.com/mutagen-io/mutagen/pkg/service/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+const ActionTimeout = time.Minute
+
+const (
+	ActionPause     = "Pause"
+	ActionResume    = "Resume"
+	ActionFlush     = "Flush"
+	ActionReset     = "Reset"
+	ActionTerminate = "Terminate"
+)
+
+var actions = []string{ActionPause, ActionResume, ActionFlush, ActionReset, ActionTerminate}
+
+// confirmed actions can't be undone, they run from a confirmation item inside their submenu
+var confirmed = map[string]bool{ActionReset: true, ActionTerminate: true}
+
+// logPrompter receives status messages of daemon operations, it never answers prompts
+type logPrompter struct{}
+
+func (logPrompter) Message(message string) error {
+	if message != "" {
+		log.Printf("[DEBUG] daemon: %s", message)
+	}
+	return nil
+}
+
+func (logPrompter) Prompt(string) (string, error) {
+	return "", fmt.Errorf("prompts are not supported")
+}
+
+// Act runs a session control action on the selected sessions
+func (self *MutagenMon) Act(ctx context.Context, action string, sessions *selection.Selection) error {
+	connection := self.connection()
+	if connection == nil {
+		return ErrNoDaemon
+	}
+	promptingCtx, promptingCancel := context.WithCancel(ctx)
+	prompter, promptingErrors, err := servicePrompting.Host(promptingCtx, servicePrompting.NewPromptingClient(connection), logPrompter{}, false)
+	if err != nil {
+		promptingCancel()
+		return fmt.Errorf("host prompter: %v", err)
+	}
+	defer func() {
+		promptingCancel()
+		<-promptingErrors
+	}()
+
+	synchronizationService := serviceSync.NewSynchronizationClient(connection)
+	switch action {
+	case ActionPause:
+		_, err = synchronizationService.Pause(ctx, &serviceSync.PauseRequest{Prompter: prompter, Selection: sessions})
+	case ActionResume:
+		_, err = synchronizationService.Resume(ctx, &serviceSync.ResumeRequest{Prompter: prompter, Selection: sessions})
+	case ActionFlush:
+		_, err = synchronizationService.Flush(ctx, &serviceSync.FlushRequest{Prompter: prompter, Selection: sessions})
+	case ActionReset:
+		_, err = synchronizationService.Reset(ctx, &serviceSync.ResetRequest{Prompter: prompter, Selection: sessions})
+	case ActionTerminate:
+		_, err = synchronizationService.Terminate(ctx, &serviceSync.TerminateRequest{Prompter: prompter, Selection: sessions})
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %v", action, grpcutil.PeelAwayRPCErrorLayer(err))
+	}
+	return nil
+}
+
+// AddActions adds session control items to the peer submenu
+func (self *Peer) AddActions(mon *MutagenMon, state *synchronization.State) {
+	id := state.Session.Identifier
+	for _, action := range actions {
+		item := self.menu.AddSubMenuItem(action, "")
+		if !confirmed[action] {
+			go mon.handleAction(item, item, action, id)
+			continue
+		}
+		label := sessionLabel(state, mon.Config())
+		confirm := item.AddSubMenuItem(fmt.Sprintf("Confirm: %s %s", strings.ToLower(action), label), id)
+		go mon.handleAction(item, confirm, action, id)
+	}
+}
+
+// handleAction runs action when click is clicked and shows the outcome on item
+func (self *MutagenMon) handleAction(item MenuItem, click MenuItem, action string, id string) {
+	for range click.Clicked() {
+		click.Disable()
+		item.SetTitle(action + " ...")
+		ctx, cancel := context.WithTimeout(self.ctx, ActionTimeout)
+		err := self.Act(ctx, action, &selection.Selection{Specifications: []string{id}})
+		cancel()
+		if err != nil {
+			log.Printf("[WARN] %s session %s: %s", action, id, err)
+			item.SetTitle(action + " ✗")
+			item.SetTooltip(err.Error())
+		} else {
+			item.SetTitle(action + " ✓")
+			item.SetTooltip("")
+		}
+		click.Enable()
+	}
+}
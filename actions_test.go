@@ -0,0 +1,38 @@
+package mutagenmon
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+func TestActionsNeedConfirmation(t *testing.T) {
+	mon, tray := newTestMonitor()
+	err := mon.CheckStates(context.Background(), testStates(testState("s", synchronization.Status_Watching)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	session := tray.Find(testLabel("s"))
+	if session == nil {
+		t.Fatal("no session item")
+	}
+	want := map[string][]string{
+		ActionPause:     {},
+		ActionResume:    {},
+		ActionFlush:     {},
+		ActionReset:     {"Confirm: reset " + testLabel("s")},
+		ActionTerminate: {"Confirm: terminate " + testLabel("s")},
+	}
+	for _, action := range actions {
+		item := session.Find(action)
+		if item == nil {
+			t.Errorf("%s: no item", action)
+			continue
+		}
+		if got := item.Visible(); !reflect.DeepEqual(got, want[action]) {
+			t.Errorf("%s: %v, want %v", action, got, want[action])
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package mutagenmon
+
+import (
+	"fmt"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+// Category is how the monitor treats a session status
+type Category string
+
+const (
+	CategoryFatal        Category = "fatal"
+	CategoryDisconnected Category = "disconnected"
+	CategoryWatching     Category = "watching"
+	CategorySyncing      Category = "syncing"
+	CategoryUnknown      Category = "unknown"
+)
+
+var categories = map[Category]struct{}{
+	CategoryFatal:        {},
+	CategoryDisconnected: {},
+	CategoryWatching:     {},
+	CategorySyncing:      {},
+	CategoryUnknown:      {},
+}
+
+// Classification maps session statuses to categories, missing statuses are unknown
+type Classification map[synchronization.Status]Category
+
+func DefaultClassification() Classification {
+	return Classification{
+		synchronization.Status_HaltedOnRootEmptied:    CategoryFatal,
+		synchronization.Status_HaltedOnRootDeletion:   CategoryFatal,
+		synchronization.Status_HaltedOnRootTypeChange: CategoryFatal,
+
+		synchronization.Status_Disconnected:   CategoryDisconnected,
+		synchronization.Status_ConnectingBeta: CategoryDisconnected,
+
+		synchronization.Status_WaitingForRescan: CategoryWatching,
+		synchronization.Status_Watching:         CategoryWatching,
+
+		synchronization.Status_ConnectingAlpha: CategorySyncing,
+		synchronization.Status_Scanning:        CategorySyncing,
+		synchronization.Status_Reconciling:     CategorySyncing,
+		synchronization.Status_StagingAlpha:    CategorySyncing,
+		synchronization.Status_StagingBeta:     CategorySyncing,
+		synchronization.Status_Transitioning:   CategorySyncing,
+		synchronization.Status_Saving:          CategorySyncing,
+	}
+}
+
+func (self Classification) Of(state *synchronization.State) Category {
+	if state == nil {
+		return CategoryUnknown
+	}
+	category, ok := self[state.Status]
+	if !ok {
+		return CategoryUnknown
+	}
+	return category
+}
+
+func (self Classification) Is(state *synchronization.State, category Category) bool {
+	return state != nil && self.Of(state) == category
+}
+
+// Bad tells if a session is disconnected or halted
+func (self Classification) Bad(state *synchronization.State) bool {
+	return self.Is(state, CategoryDisconnected) || self.Is(state, CategoryFatal)
+}
+
+// ParseStatus accepts status names as mutagen prints them in JSON, e.g. "connecting-beta"
+func ParseStatus(name string) (synchronization.Status, error) {
+	for value := range synchronization.Status_name {
+		status := synchronization.Status(value)
+		if statusName(status) == name {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown status %q", name)
+}
+
+// Override returns a copy of classification with statuses moved to other categories
+func (self Classification) Override(overrides map[string]Category) (Classification, error) {
+	result := Classification{}
+	for status, category := range self {
+		result[status] = category
+	}
+	for name, category := range overrides {
+		status, err := ParseStatus(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := categories[category]; !ok {
+			return nil, fmt.Errorf("unknown category %q for status %q", category, name)
+		}
+		result[status] = category
+	}
+	return result, nil
+}
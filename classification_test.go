@@ -0,0 +1,94 @@
+package mutagenmon
+
+import (
+	"testing"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+func TestDefaultClassificationCoversAllStatuses(t *testing.T) {
+	classes := DefaultClassification()
+	for value, name := range synchronization.Status_name {
+		state := &synchronization.State{Status: synchronization.Status(value)}
+		if category := classes.Of(state); category == CategoryUnknown {
+			t.Errorf("status %s is not classified", name)
+		}
+	}
+}
+
+func TestDefaultClassification(t *testing.T) {
+	tests := []struct {
+		status   synchronization.Status
+		category Category
+		bad      bool
+	}{
+		{synchronization.Status_Disconnected, CategoryDisconnected, true},
+		{synchronization.Status_HaltedOnRootEmptied, CategoryFatal, true},
+		{synchronization.Status_HaltedOnRootDeletion, CategoryFatal, true},
+		{synchronization.Status_HaltedOnRootTypeChange, CategoryFatal, true},
+		{synchronization.Status_ConnectingAlpha, CategorySyncing, false},
+		{synchronization.Status_ConnectingBeta, CategoryDisconnected, true},
+		{synchronization.Status_Watching, CategoryWatching, false},
+		{synchronization.Status_Scanning, CategorySyncing, false},
+		{synchronization.Status_WaitingForRescan, CategoryWatching, false},
+		{synchronization.Status_Reconciling, CategorySyncing, false},
+		{synchronization.Status_StagingAlpha, CategorySyncing, false},
+		{synchronization.Status_StagingBeta, CategorySyncing, false},
+		{synchronization.Status_Transitioning, CategorySyncing, false},
+		{synchronization.Status_Saving, CategorySyncing, false},
+	}
+	classes := DefaultClassification()
+	for _, test := range tests {
+		state := &synchronization.State{Status: test.status}
+		if category := classes.Of(state); category != test.category {
+			t.Errorf("%s: expected %s, got %s", test.status, test.category, category)
+		}
+		if bad := classes.Bad(state); bad != test.bad {
+			t.Errorf("%s: expected bad %v, got %v", test.status, test.bad, bad)
+		}
+	}
+	if category := classes.Of(nil); category != CategoryUnknown {
+		t.Errorf("nil state: expected unknown, got %s", category)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	for value := range synchronization.Status_name {
+		status := synchronization.Status(value)
+		parsed, err := ParseStatus(statusName(status))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed != status {
+			t.Errorf("expected %s, got %s", status, parsed)
+		}
+	}
+	if _, err := ParseStatus("no-such-status"); err == nil {
+		t.Error("expected error for unknown status")
+	}
+}
+
+func TestClassificationOverride(t *testing.T) {
+	defaults := DefaultClassification()
+	classes, err := defaults.Override(map[string]Category{"connecting-alpha": CategoryDisconnected})
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := &synchronization.State{Status: synchronization.Status_ConnectingAlpha}
+	if category := classes.Of(state); category != CategoryDisconnected {
+		t.Errorf("expected override to disconnected, got %s", category)
+	}
+	if category := defaults.Of(state); category != CategorySyncing {
+		t.Errorf("override changed defaults to %s", category)
+	}
+
+	tests := []map[string]Category{
+		{"no-such-status": CategoryFatal},
+		{"watching": "broken"},
+	}
+	for _, overrides := range tests {
+		if _, err := defaults.Override(overrides); err == nil {
+			t.Errorf("expected error for %v", overrides)
+		}
+	}
+}
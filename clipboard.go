@@ -0,0 +1,32 @@
+package mutagenmon
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardCommands are tried in order until one is installed
+var clipboardCommands = map[string][][]string{
+	"darwin":  {{"pbcopy"}},
+	"linux":   {{"wl-copy"}, {"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}},
+	"windows": {{"clip"}},
+}
+
+func copyToClipboard(text string) error {
+	for _, command := range clipboardCommands[runtime.GOOS] {
+		path, err := exec.LookPath(command[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, command[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		output, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("%s: %v: %s", command[0], err, output)
+		}
+		return nil
+	}
+	return fmt.Errorf("no clipboard command found")
+}
@@ -1,24 +1,122 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/mutagen-io/mutagen/pkg/selection"
 	"go.andmed.org/mutagenmon"
 	"log"
+	"os"
 	"time"
 )
 
-func main() {
-	var err error
+// connect creates a monitor that keeps trying to reach the daemon
+func connect() *mutagenmon.MutagenMon {
+	mm, err := mutagenmon.New()
+	if err != nil {
+		log.Printf("[INFO] waiting for mutagen daemon: %s", err)
+	}
+	return mm
+}
+
+func metrics(args []string) {
+	flags := flag.NewFlagSet("metrics", flag.ExitOnError)
+	listen := flags.String("listen", ":9457", "address to serve /metrics on")
+	flags.Parse(args)
+
+	err := connect().ServeMetrics(*listen)
+	if err != nil {
+		log.Fatalln("serve metrics:", err)
+	}
+}
+
+// status prints tray title counts, exit code is 1 if any session is bad and 2 on errors
+func status(args []string) {
+	flags := flag.NewFlagSet("status", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print counts and per-session details as JSON")
+	timeout := flags.Duration("timeout", 10*time.Second, "daemon request timeout")
+	flags.Parse(args)
+
+	mm, err := mutagenmon.New()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	summary, err := mm.Status(ctx)
+	cancel()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(summary)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	} else {
+		title, err := summary.Render(mm.Config().TitleTemplate)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Println(title)
+	}
+	if summary.Bad > 0 {
+		os.Exit(1)
+	}
+}
+
+// wait flushes sessions given by identifier, name or label and waits until they are synced
+func wait(args []string) {
+	flags := flag.NewFlagSet("wait", flag.ExitOnError)
+	labelSelector := flags.String("label-selector", "", "wait for sessions matching the label selector")
+	timeout := flags.Duration("timeout", 5*time.Minute, "give up after this long")
+	flags.Parse(args)
+
+	sessions := &selection.Selection{
+		Specifications: flags.Args(),
+		LabelSelector:  *labelSelector,
+	}
+	if len(sessions.Specifications) == 0 && sessions.LabelSelector == "" {
+		sessions.All = true
+	}
+	mm, err := mutagenmon.New()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	err = mm.WaitSynced(ctx, sessions)
+	cancel()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
 
+func main() {
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
-	var mm *mutagenmon.MutagenMon
-	for {
-		mm, err = mutagenmon.New()
-		if err == nil {
-			break
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "metrics":
+			metrics(os.Args[2:])
+			return
+		case "status":
+			status(os.Args[2:])
+			return
+		case "wait":
+			wait(os.Args[2:])
+			return
+		default:
+			log.Fatalf("unknown command %q, expected: metrics, status, wait", os.Args[1])
 		}
-		log.Printf("[Info] waiting for initialization\n")
-		time.Sleep(3 * time.Second)
 	}
-	mm.Run()
+	connect().Run()
 }
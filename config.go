@@ -0,0 +1,322 @@
+package mutagenmon
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// ConfigCheckInterval is how often the config file is checked for changes
+const ConfigCheckInterval = 2 * time.Second
+
+// Config is read from config.yaml in the XDG config directory, every field is optional
+type Config struct {
+	// Interval is the pause between retries when the daemon fails
+	Interval time.Duration `yaml:"interval"`
+	// Liveness bounds a single long-poll of the daemon
+	Liveness time.Duration `yaml:"liveness"`
+	// MaxConflicts is how many conflicts are listed per session
+	MaxConflicts int `yaml:"max_conflicts"`
+	// MaxPathLength is where paths in the menu get shortened
+	MaxPathLength int `yaml:"max_path_length"`
+	// TrayIcon is "ring" to draw aggregate health as the tray icon or "static" for icon.png
+	TrayIcon string `yaml:"tray_icon"`
+	// Title and Tooltip are text/template formats rendered with Summary
+	Title   string `yaml:"title"`
+	Tooltip string `yaml:"tooltip"`
+	// SessionLabel and SessionTooltip are text/template formats for session menu items rendered with SessionInfo
+	SessionLabel   string `yaml:"session_label"`
+	SessionTooltip string `yaml:"session_tooltip"`
+	// IconDir overrides state icons, relative paths are resolved against the config file directory
+	IconDir string `yaml:"icon_dir"`
+	// GroupBy puts sessions into submenus by beta host, a label value or name prefix:
+	// "host", "label:<key>" or "prefix:<separator>"
+	GroupBy string `yaml:"group_by"`
+	// Selection limits sessions listed by the daemon, Presets are alternatives that can be picked in the menu
+	Selection SessionFilter            `yaml:"selection"`
+	Presets   map[string]SessionFilter `yaml:"presets"`
+	// Include and Exclude are glob patterns matched against session name, identifier and menu label
+	Include []string `yaml:"include"`
+	Exclude []string `yaml:"exclude"`
+	// RemoveHook, if set, is run with sh -c instead of ssh/docker to remove a conflicting path on a remote endpoint.
+	// The endpoint is passed in MUTAGENMON_PROTOCOL, MUTAGENMON_USER, MUTAGENMON_HOST, MUTAGENMON_PORT
+	// and the absolute path to remove in MUTAGENMON_PATH.
+	RemoveHook string `yaml:"remove_hook"`
+	// Statuses moves statuses to other categories, e.g. connecting-alpha: disconnected
+	Statuses map[string]Category `yaml:"statuses"`
+
+	// Classification is the default classification with Statuses applied
+	Classification Classification `yaml:"-"`
+	// TitleTemplate and TooltipTemplate are parsed Title and Tooltip
+	TitleTemplate   *template.Template `yaml:"-"`
+	TooltipTemplate *template.Template `yaml:"-"`
+	// SessionLabelTemplate and SessionTooltipTemplate are parsed SessionLabel and SessionTooltip
+	SessionLabelTemplate   *template.Template `yaml:"-"`
+	SessionTooltipTemplate *template.Template `yaml:"-"`
+}
+
+const DefaultTitle = `{{.Healthy}}{{if .Syncing}}•{{else}}-{{end}}{{.Connected}}`
+
+const DefaultTooltip = `{{.Total}} sessions: {{.Healthy}} healthy, {{.Conflicts}} with conflicts, {{.Bad}} disconnected or halted` +
+	`{{if .Forwards}}, {{.Forwards}} forwards{{end}}`
+
+func DefaultConfig() Config {
+	return Config{
+		Interval:        InitInterval,
+		Liveness:        LivenessInterval,
+		MaxConflicts:    60,
+		MaxPathLength:   70,
+		TrayIcon:        TrayIconRing,
+		Title:           DefaultTitle,
+		Tooltip:         DefaultTooltip,
+		SessionLabel:    DefaultSessionLabel,
+		SessionTooltip:  DefaultSessionTooltip,
+		Classification:  DefaultClassification(),
+		TitleTemplate:   template.Must(template.New("title").Parse(DefaultTitle)),
+		TooltipTemplate: template.Must(template.New("tooltip").Parse(DefaultTooltip)),
+
+		SessionLabelTemplate:   template.Must(template.New("session_label").Parse(DefaultSessionLabel)),
+		SessionTooltipTemplate: template.Must(template.New("session_tooltip").Parse(DefaultSessionTooltip)),
+	}
+}
+
+// ConfigPath is $MUTAGENMON_CONFIG or mutagenmon/config.yaml under $XDG_CONFIG_HOME (~/.config by default)
+func ConfigPath() string {
+	if path := os.Getenv("MUTAGENMON_CONFIG"); path != "" {
+		return path
+	}
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "mutagenmon", "config.yaml")
+}
+
+// LoadConfig reads and validates config, a missing file gives defaults
+func LoadConfig(file string) (Config, error) {
+	config := DefaultConfig()
+	if file == "" {
+		return config, nil
+	}
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("read config: %v", err)
+	}
+	err = yaml.UnmarshalStrict(b, &config)
+	if err != nil {
+		return DefaultConfig(), fmt.Errorf("parse config %s: %v", file, err)
+	}
+	err = config.Validate()
+	if err != nil {
+		return DefaultConfig(), fmt.Errorf("invalid config %s: %v", file, err)
+	}
+	if config.IconDir != "" && !filepath.IsAbs(config.IconDir) {
+		config.IconDir = filepath.Join(filepath.Dir(file), config.IconDir)
+	}
+	config.TitleTemplate, err = parseTitle("title", config.Title)
+	if err != nil {
+		return DefaultConfig(), fmt.Errorf("invalid config %s: %v", file, err)
+	}
+	config.TooltipTemplate, err = parseTitle("tooltip", config.Tooltip)
+	if err != nil {
+		return DefaultConfig(), fmt.Errorf("invalid config %s: %v", file, err)
+	}
+	config.SessionLabelTemplate, err = parseSessionLabel("session_label", config.SessionLabel)
+	if err != nil {
+		return DefaultConfig(), fmt.Errorf("invalid config %s: %v", file, err)
+	}
+	config.SessionTooltipTemplate, err = parseSessionLabel("session_tooltip", config.SessionTooltip)
+	if err != nil {
+		return DefaultConfig(), fmt.Errorf("invalid config %s: %v", file, err)
+	}
+	config.Classification, err = DefaultClassification().Override(config.Statuses)
+	if err != nil {
+		return DefaultConfig(), fmt.Errorf("invalid config %s: statuses: %v", file, err)
+	}
+	return config, nil
+}
+
+// parseTitle parses a title template and checks that it renders
+func parseTitle(name string, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	_, err = Summary{}.Render(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// parseSessionLabel parses a session label template and checks that it renders
+func parseSessionLabel(name string, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	_, err = SessionInfo{}.Render(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+func (self Config) Validate() error {
+	if self.Interval < 100*time.Millisecond {
+		return fmt.Errorf("interval must be at least 100ms")
+	}
+	if self.Liveness < time.Second {
+		return fmt.Errorf("liveness must be at least 1s")
+	}
+	if self.MaxConflicts < 1 {
+		return fmt.Errorf("max_conflicts must be positive")
+	}
+	if self.MaxPathLength < 25 {
+		return fmt.Errorf("max_path_length must be at least 25")
+	}
+	if self.TrayIcon != TrayIconRing && self.TrayIcon != TrayIconStatic {
+		return fmt.Errorf("tray_icon must be %s or %s", TrayIconRing, TrayIconStatic)
+	}
+	err := validGroupBy(self.GroupBy)
+	if err != nil {
+		return err
+	}
+	err = self.Selection.Validate()
+	if err != nil {
+		return fmt.Errorf("selection: %v", err)
+	}
+	for name, preset := range self.Presets {
+		if name == "" {
+			return fmt.Errorf("presets: empty name")
+		}
+		err = preset.Validate()
+		if err != nil {
+			return fmt.Errorf("preset %s: %v", name, err)
+		}
+	}
+	for _, pattern := range append(append([]string{}, self.Include...), self.Exclude...) {
+		_, err := path.Match(pattern, "")
+		if err != nil {
+			return fmt.Errorf("bad pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// Shorten cuts the middle out of long paths
+func (self Config) Shorten(path string) string {
+	if len(path) > self.MaxPathLength {
+		return path[:self.MaxPathLength-20] + " ... " + path[len(path)-15:]
+	}
+	return path
+}
+
+func matchAny(patterns []string, names ...string) bool {
+	for _, pattern := range patterns {
+		for _, name := range names {
+			if ok, _ := path.Match(pattern, name); ok && name != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Shown tells if a session with these names passes include and exclude filters
+func (self Config) Shown(names ...string) bool {
+	if len(self.Include) > 0 && !matchAny(self.Include, names...) {
+		return false
+	}
+	return !matchAny(self.Exclude, names...)
+}
+
+// Config returns current configuration
+func (self *MutagenMon) Config() Config {
+	self.configLock.Lock()
+	defer self.configLock.Unlock()
+	return self.config
+}
+
+func (self *MutagenMon) setConfig(config Config) {
+	self.configLock.Lock()
+	self.config = config
+	self.missing = map[string]bool{}
+	self.configLock.Unlock()
+	setIconDir(config.IconDir)
+}
+
+// WatchConfig reloads the config file when it changes, invalid configs are logged and ignored
+func (self *MutagenMon) WatchConfig(ctx context.Context) {
+	file := ConfigPath()
+	var modified time.Time
+	if info, err := os.Stat(file); err == nil {
+		modified = info.ModTime()
+	}
+	ticker := time.NewTicker(ConfigCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var current time.Time
+		if info, err := os.Stat(file); err == nil {
+			current = info.ModTime()
+		}
+		if current.Equal(modified) {
+			continue
+		}
+		modified = current
+		config, err := LoadConfig(file)
+		if err != nil {
+			log.Printf("[WARN] keeping previous config: %s", err)
+			continue
+		}
+		log.Printf("[INFO] reloaded config %s", file)
+		self.setConfig(config)
+		self.updateFilters()
+		self.Refresh()
+	}
+}
+
+// Refresh interrupts running long-polls so schedulers redraw the menu with current config,
+// schedulers that are not polling right now see the new generation before their next poll
+func (self *MutagenMon) Refresh() {
+	self.refreshLock.Lock()
+	defer self.refreshLock.Unlock()
+	self.refreshes++
+	self.refreshCancel()
+	self.refreshCtx, self.refreshCancel = context.WithCancel(context.Background())
+}
+
+// pollContext bounds a long-poll by liveness interval, Refresh cancels it too.
+// It returns the refresh generation the poll belongs to.
+func (self *MutagenMon) pollContext(ctx context.Context) (context.Context, context.CancelFunc, uint64) {
+	pollCtx, cancel := context.WithTimeout(ctx, self.Config().Liveness)
+	self.refreshLock.Lock()
+	refreshCtx := self.refreshCtx
+	generation := self.refreshes
+	self.refreshLock.Unlock()
+	stop := context.AfterFunc(refreshCtx, cancel)
+	return pollCtx, func() {
+		stop()
+		cancel()
+	}, generation
+}
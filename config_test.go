@@ -0,0 +1,72 @@
+package mutagenmon
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefaultTitle(t *testing.T) {
+	config := DefaultConfig()
+	tests := []struct {
+		summary Summary
+		title   string
+	}{
+		{Summary{Healthy: 2, Connected: 3}, "2-3"},
+		{Summary{Healthy: 0, Connected: 4, Syncing: true}, "0•4"},
+	}
+	for _, test := range tests {
+		title, err := test.summary.Render(config.TitleTemplate)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if title != test.title {
+			t.Errorf("expected %q, got %q", test.title, title)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{"empty", "", true},
+		{"title", `title: "⇅ {{.Connected}}/{{.Total}} ⚠{{.Conflicts}}"`, true},
+		{"bad template", `title: "{{.Healthy"`, false},
+		{"unknown field in template", `title: "{{.Nope}}"`, false},
+		{"unknown key", `nope: 1`, false},
+		{"bad status", "statuses:\n  nope: fatal", false},
+		{"bad pattern", `include: ["["]`, false},
+		{"short interval", `interval: 1ms`, false},
+		{"label selection", "selection:\n  label_selector: team=core", true},
+		{"bad label selector", "selection:\n  label_selector: '=='", false},
+		{"two selections", "presets:\n  both:\n    label_selector: a=b\n    sessions: [web]", false},
+		{"group by label", `group_by: "label:project"`, true},
+		{"bad group by", `group_by: beta`, false},
+	}
+	for _, test := range tests {
+		file := filepath.Join(dir, test.name+".yaml")
+		err := os.WriteFile(file, []byte(test.content), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = LoadConfig(file)
+		if test.valid && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+	}
+
+	config, err := LoadConfig(filepath.Join(dir, "missing.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MaxConflicts != DefaultConfig().MaxConflicts {
+		t.Errorf("missing config should give defaults")
+	}
+}
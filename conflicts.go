@@ -0,0 +1,139 @@
+package mutagenmon
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/synchronization/core"
+)
+
+// describeEntry tells what is on disk, entries carry no sizes so files are told apart by digest
+func describeEntry(entry *core.Entry) string {
+	if entry == nil {
+		return "nothing"
+	}
+	switch entry.Kind {
+	case core.EntryKind_File:
+		description := "file"
+		if entry.Executable {
+			description = "executable file"
+		}
+		if len(entry.Digest) >= 4 {
+			description += fmt.Sprintf(" %x", entry.Digest[:4])
+		}
+		return description
+	case core.EntryKind_Directory:
+		// conflicts come slimmed down, directory contents are not sent
+		return "directory"
+	case core.EntryKind_SymbolicLink:
+		return "symlink to " + entry.Target
+	case core.EntryKind_Untracked:
+		return "untracked"
+	case core.EntryKind_Problematic:
+		return "problem: " + entry.Problem
+	}
+	return entry.Kind.String()
+}
+
+func describeEntryChange(change *core.Change, config Config) string {
+	path := change.Path
+	if path == "" {
+		path = "<root>"
+	}
+	return fmt.Sprintf("%s: %s → %s", config.Shorten(path), describeEntry(change.Old), describeEntry(change.New))
+}
+
+// conflictKey identifies a conflict together with its changes, so changed conflicts get new items
+func conflictKey(conflict *core.Conflict, config Config) string {
+	var key strings.Builder
+	key.WriteString(conflict.Root)
+	for _, changes := range [][]*core.Change{conflict.AlphaChanges, conflict.BetaChanges} {
+		key.WriteString("\x00")
+		for _, change := range changes {
+			if change != nil {
+				key.WriteString(describeEntryChange(change, config))
+			}
+		}
+	}
+	return key.String()
+}
+
+// conflictKeys lists keys of all conflicts of a session
+func conflictKeys(state *synchronization.State, config Config) []string {
+	var keys []string
+	for _, conflict := range state.GetConflicts() {
+		if conflict != nil {
+			keys = append(keys, conflictKey(conflict, config))
+		}
+	}
+	return keys
+}
+
+func conflictTitle(conflict *core.Conflict, config Config) string {
+	root := conflict.Root
+	if root == "" {
+		root = "<root>"
+	}
+	return fmt.Sprintf("%s (alpha %d, beta %d changes)", config.Shorten(root), len(conflict.AlphaChanges), len(conflict.BetaChanges))
+}
+
+// AddConflict adds a conflict submenu with resolutions and both sides' changes
+func (self *MutagenMon) AddConflict(parent MenuItem, session *synchronization.Session, conflict *core.Conflict) MenuItem {
+	config := self.Config()
+	item := parent.AddSubMenuItem(conflictTitle(conflict, config), conflict.Root)
+	self.AddResolutions(item, session, conflict.Root)
+	for _, side := range []struct {
+		name    string
+		changes []*core.Change
+	}{{"alpha", conflict.AlphaChanges}, {"beta", conflict.BetaChanges}} {
+		for _, change := range side.changes {
+			if change == nil {
+				continue
+			}
+			line := item.AddSubMenuItem(fmt.Sprintf("%s  %s", side.name, describeEntryChange(change, config)), change.Path)
+			line.Disable()
+		}
+	}
+	return item
+}
+
+// updateConflicts syncs conflict submenus of the peer with state
+func (self *Peer) updateConflicts(item MenuItem, state *synchronization.State) {
+	config := self.mon.Config()
+	conflicts := map[string]MenuItem{}
+	shown := min(len(state.GetConflicts()), config.MaxConflicts)
+	for _, conflict := range state.GetConflicts()[:shown] {
+		if conflict == nil {
+			continue
+		}
+		key := conflictKey(conflict, config)
+		if c, ok := self.conflicts[key]; ok {
+			conflicts[key] = c
+			delete(self.conflicts, key)
+		} else {
+			conflicts[key] = self.mon.AddConflict(item, state.Session, conflict)
+		}
+	}
+	for _, conflict := range self.conflicts {
+		conflict.Hide()
+	}
+	self.conflicts = conflicts
+
+	// the daemon lists a few conflicts and only counts the rest
+	more := len(state.GetConflicts()) - shown + int(state.GetExcludedConflicts())
+	if more <= 0 {
+		if self.more != nil {
+			self.more.Hide()
+		}
+		return
+	}
+	title := fmt.Sprintf("... and %d more", more)
+	if self.more == nil {
+		self.more = item.AddSubMenuItem(title, "")
+		self.more.Disable()
+		return
+	}
+	self.more.SetTitle(title)
+	self.more.Show()
+}
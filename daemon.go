@@ -0,0 +1,185 @@
+package mutagenmon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/mutagen-io/mutagen/cmd/mutagen/daemon"
+	daemon2 "github.com/mutagen-io/mutagen/pkg/daemon"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const NoDaemonTitle = "no daemon"
+
+const NoDaemonTooltip = "Mutagen daemon is not running"
+
+const ListErrorTitle = "error"
+
+// ErrNoDaemon is returned by daemon requests while there is no connection
+var ErrNoDaemon = errors.New("no daemon connection")
+
+// SessionSource dials the daemon that lists and controls sessions
+type SessionSource interface {
+	Connect() (*grpc.ClientConn, error)
+}
+
+// DaemonSource is the local mutagen daemon
+type DaemonSource struct{}
+
+// Connect dials the running mutagen daemon, it never starts one by itself
+func (DaemonSource) Connect() (*grpc.ClientConn, error) {
+	lock, err := daemon2.AcquireLock()
+	if err == nil {
+		// should not be here if daemon is running
+		err = lock.Release()
+		if err != nil {
+			return nil, fmt.Errorf("no daemon is running, release daemon lock: %v", err)
+		}
+		return nil, fmt.Errorf("no daemon is running")
+	}
+	connection, err := daemon.Connect(false, false)
+	if err != nil {
+		return nil, fmt.Errorf("connect to mutagen daemon: %v", err)
+	}
+	return connection, nil
+}
+
+// daemonLost tells if err means that connection to the daemon is gone
+func daemonLost(err error) bool {
+	if errors.Is(err, ErrNoDaemon) {
+		return true
+	}
+	for ; err != nil; err = errors.Unwrap(err) {
+		if status.Code(err) == codes.Unavailable {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *MutagenMon) connection() *grpc.ClientConn {
+	self.daemonLock.Lock()
+	defer self.daemonLock.Unlock()
+	return self.daemon
+}
+
+// Disconnected drops the daemon connection and marks all peers as unknown
+func (self *MutagenMon) Disconnected() {
+	self.daemonLock.Lock()
+	if self.daemon != nil {
+		err := self.daemon.Close()
+		if err != nil {
+			log.Printf("[WARN] close daemon connection: %s", err)
+		}
+		self.daemon = nil
+	}
+	self.showStart()
+	self.daemonLock.Unlock()
+	self.markUnknown(NoDaemonTitle, NoDaemonTooltip)
+}
+
+// ListFailed marks all peers as unknown and shows why the daemon could not list sessions
+func (self *MutagenMon) ListFailed(err error) {
+	self.markUnknown(ListErrorTitle, "Listing sessions failed: "+err.Error())
+}
+
+// markUnknown forgets peer states, so they are redrawn by the next successful list, and sets title and tooltip
+func (self *MutagenMon) markUnknown(title string, tooltip string) {
+	self.stateIndex = 0
+	for id, peer := range self.peers {
+		peer.menu.SetIcon(Icon("unknown.png"))
+		peer.state = nil
+		self.peers[id] = peer
+	}
+	for _, group := range self.groups {
+		group.menu.SetIcon(Icon("unknown.png"))
+		group.icon = ""
+	}
+	self.titleLock.Lock()
+	self.title = title
+	self.tooltip = tooltip
+	self.updateTrayIcon(Summary{}, self.Config())
+	self.titleLock.Unlock()
+	self.tray.SetTitle(title)
+	self.tray.SetTooltip(tooltip)
+}
+
+// redial replaces the daemon connection stale with a new one. Callers that lost the same
+// connection at once reconnect only once, the others find it replaced and use the new one.
+func (self *MutagenMon) redial(stale *grpc.ClientConn) error {
+	self.redialLock.Lock()
+	defer self.redialLock.Unlock()
+	if current := self.connection(); current != nil && current != stale {
+		return nil
+	}
+	connection, err := self.source.Connect()
+	if err != nil {
+		return err
+	}
+	self.daemonLock.Lock()
+	defer self.daemonLock.Unlock()
+	if self.daemon != nil {
+		self.daemon.Close()
+	}
+	self.daemon = connection
+	self.showStart()
+	log.Printf("[INFO] connected to mutagen daemon")
+	return nil
+}
+
+// Reconnect blocks until the daemon is reachable again or ctx is done
+func (self *MutagenMon) Reconnect(ctx context.Context) {
+	for {
+		err := self.redial(nil)
+		if err == nil {
+			return
+		}
+		log.Printf("[DEBUG] waiting for daemon: %s", err)
+		if !sleep(ctx, self.Config().Interval) {
+			return
+		}
+	}
+}
+
+// sleep waits for duration, false means ctx was done first
+func sleep(ctx context.Context, duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Stop cancels schedulers and watchers, waits for them to return and closes the daemon connection
+func (self *MutagenMon) Stop() {
+	self.cancel()
+	done := make(chan struct{})
+	go func() {
+		self.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(ShutdownTimeout):
+		log.Printf("[WARN] schedulers did not stop in %s", ShutdownTimeout)
+	}
+	self.notifier.Stop()
+	self.daemonLock.Lock()
+	defer self.daemonLock.Unlock()
+	if self.daemon != nil {
+		err := self.daemon.Close()
+		if err != nil {
+			log.Printf("[WARN] close daemon connection: %s", err)
+		}
+		self.daemon = nil
+	}
+	log.Printf("[INFO] stopped")
+}
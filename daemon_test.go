@@ -0,0 +1,330 @@
+package mutagenmon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func newFakeMonitor(t *testing.T) (*MutagenMon, *FakeTray, *FakeDaemon) {
+	fake := NewFakeDaemon()
+	t.Cleanup(fake.Stop)
+	connection, err := fake.Connect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tray := &FakeTray{}
+	mon := newMonitor(tray, fake, connection)
+	mon.notifier.send = func(string, string) error { return nil }
+	mon.config.Interval = 10 * time.Millisecond
+	mon.config.Liveness = 100 * time.Millisecond
+	t.Cleanup(func() {
+		if connection := mon.connection(); connection != nil {
+			connection.Close()
+		}
+	})
+	return mon, tray, fake
+}
+
+func TestLongPoll(t *testing.T) {
+	mon, _, fake := newFakeMonitor(t)
+	ctx := context.Background()
+	fake.Respond(1, testState("a", synchronization.Status_Watching))
+	fake.Respond(2, testState("a", synchronization.Status_Watching), testState("b", synchronization.Status_Scanning))
+
+	index, states, err := mon.WaitSessionStates(ctx, 0)
+	if err != nil || index != 1 || len(states) != 1 {
+		t.Fatalf("first poll: %d %d %v", index, len(states), err)
+	}
+	index, states, err = mon.WaitSessionStates(ctx, index)
+	if err != nil || index != 2 || len(states) != 2 {
+		t.Fatalf("second poll: %d %d %v", index, len(states), err)
+	}
+
+	// nothing changes, the poll runs into liveness timeout the way Scheduler expects
+	pollCtx, cancel, _ := mon.pollContext(ctx)
+	_, _, err = mon.WaitSessionStates(pollCtx, index)
+	cancel()
+	if status.Code(errors.Unwrap(err)) != codes.DeadlineExceeded || daemonLost(err) {
+		t.Errorf("idle poll: %v", err)
+	}
+
+	// refresh interrupts a long-poll
+	pollCtx, cancel, _ = mon.pollContext(ctx)
+	time.AfterFunc(10*time.Millisecond, mon.Refresh)
+	_, _, err = mon.WaitSessionStates(pollCtx, index)
+	refreshed := errors.Is(pollCtx.Err(), context.Canceled)
+	cancel()
+	if err == nil || !refreshed {
+		t.Errorf("refresh: %v", err)
+	}
+
+	requests := fake.Requests()
+	var previous []uint64
+	for _, request := range requests {
+		previous = append(previous, request.PreviousStateIndex)
+		if !request.Selection.All {
+			t.Errorf("default selection should be all sessions: %v", request.Selection)
+		}
+	}
+	if len(previous) != 4 || previous[0] != 0 || previous[1] != 1 || previous[2] != 2 || previous[3] != 2 {
+		t.Errorf("previous state indexes %v", previous)
+	}
+}
+
+func TestListErrors(t *testing.T) {
+	mon, _, fake := newFakeMonitor(t)
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		err  error
+		lost bool
+	}{
+		{"internal", status.Error(codes.Internal, "boom"), false},
+		{"unavailable", status.Error(codes.Unavailable, "shutting down"), true},
+		{"not found", status.Error(codes.NotFound, "unable to locate requested sessions"), false},
+	}
+	for _, test := range tests {
+		fake.Fail(test.err)
+		_, _, err := mon.WaitSessionStates(ctx, 0)
+		if err == nil {
+			t.Errorf("%s: expected error", test.name)
+			continue
+		}
+		if daemonLost(err) != test.lost {
+			t.Errorf("%s: daemonLost(%v) = %v", test.name, err, !test.lost)
+		}
+	}
+	fake.Respond(5)
+	index, _, err := mon.WaitSessionStates(ctx, 0)
+	if err != nil || index != 5 {
+		t.Errorf("after errors: %d %v", index, err)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	mon, tray, fake := newFakeMonitor(t)
+	ctx := context.Background()
+	fake.Respond(1, testState("a", synchronization.Status_Watching))
+	index, states, err := mon.WaitSessionStates(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mon.stateIndex = index
+	err = mon.CheckStates(ctx, states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tray.title != "1-1" {
+		t.Errorf("title %q", tray.title)
+	}
+
+	fake.Stop()
+	_, _, err = mon.WaitSessionStates(ctx, mon.stateIndex)
+	if !daemonLost(err) {
+		t.Fatalf("stopped daemon: %v", err)
+	}
+	mon.Disconnected()
+	if tray.title != NoDaemonTitle || mon.stateIndex != 0 || mon.connection() != nil {
+		t.Errorf("not disconnected: %q %d", tray.title, mon.stateIndex)
+	}
+	if mon.peers["a"].state != nil {
+		t.Error("peer state should be forgotten")
+	}
+	if mon.redial(nil) == nil {
+		t.Error("redial should fail while daemon is down")
+	}
+
+	fake.Start()
+	mon.Reconnect(ctx)
+	fake.Respond(7, testState("a", synchronization.Status_Watching), testState("b", synchronization.Status_Watching))
+	index, states, err = mon.WaitSessionStates(ctx, mon.stateIndex)
+	if err != nil || index != 7 {
+		t.Fatalf("after reconnect: %d %v", index, err)
+	}
+	err = mon.CheckStates(ctx, states)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tray.title != "2-2" {
+		t.Errorf("title after reconnect %q", tray.title)
+	}
+}
+
+// waitTitle polls the fake tray until a scheduler sets title
+func waitTitle(t *testing.T, tray *FakeTray, title string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for tray.Title() != title {
+		if time.Now().After(deadline) {
+			t.Fatalf("title %q, want %q", tray.Title(), title)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSchedulerStop(t *testing.T) {
+	tests := []struct {
+		name string
+		lose bool // daemon goes away, Stop interrupts reconnection
+	}{
+		{"polling", false},
+		{"reconnecting", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mon, tray, fake := newFakeMonitor(t)
+			fake.Respond(1, testState("a", synchronization.Status_Watching))
+			mon.start(mon.Scheduler)
+			waitTitle(t, tray, "1-1")
+			if test.lose {
+				fake.Stop()
+				waitTitle(t, tray, NoDaemonTitle)
+			}
+			stopped := make(chan struct{})
+			go func() {
+				mon.Stop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-time.After(ShutdownTimeout / 2):
+				t.Fatal("scheduler did not stop")
+			}
+			if mon.connection() != nil {
+				t.Error("connection should be closed")
+			}
+		})
+	}
+}
+
+func TestWaitForDaemon(t *testing.T) {
+	t.Setenv("MUTAGENMON_CONFIG", filepath.Join(t.TempDir(), "config.yaml"))
+	fake := NewFakeDaemon()
+	t.Cleanup(fake.Stop)
+	fake.Stop()
+	tray := &FakeTray{}
+	mon := newMonitor(tray, fake, nil)
+	mon.notifier.send = func(string, string) error { return nil }
+	mon.config.Interval = 10 * time.Millisecond
+	mon.Init()
+	defer mon.Stop()
+
+	waitTitle(t, tray, NoDaemonTitle)
+	start := tray.Find(StartDaemonTitle)
+	if start == nil || !start.Shown() {
+		t.Fatal("start item should be shown while there is no daemon")
+	}
+
+	fake.Start()
+	fake.Respond(1, testState("a", synchronization.Status_Watching))
+	waitTitle(t, tray, "1-1")
+	if start.Shown() {
+		t.Error("start item should be hidden once connected")
+	}
+}
+
+func TestConcurrentScrapesRedialOnce(t *testing.T) {
+	mon, _, fake := newFakeMonitor(t)
+	fake.Stop()
+	fake.Start()
+	const scrapes = 8
+	for n := 0; n < scrapes; n++ {
+		fake.Respond(1, testState("a", synchronization.Status_Watching))
+	}
+	var wait sync.WaitGroup
+	bodies := make([]string, scrapes)
+	for n := 0; n < scrapes; n++ {
+		wait.Add(1)
+		go func(n int) {
+			defer wait.Done()
+			recorder := httptest.NewRecorder()
+			mon.metricsHandler(recorder, httptest.NewRequest("GET", "/metrics", nil))
+			bodies[n] = recorder.Body.String()
+		}(n)
+	}
+	wait.Wait()
+	if connects := fake.Connects(); connects != 2 {
+		t.Errorf("%d connects, want the initial one and a single redial", connects)
+	}
+	for n, body := range bodies {
+		if !strings.Contains(body, "mutagen_daemon_up 1") {
+			t.Errorf("scrape %d failed:\n%s", n, body)
+		}
+	}
+}
+
+func TestListErrorShown(t *testing.T) {
+	mon, tray, fake := newFakeMonitor(t)
+	fake.Respond(1, testState("a", synchronization.Status_Watching))
+	mon.start(mon.Scheduler)
+	defer mon.Stop()
+	waitTitle(t, tray, "1-1")
+
+	fake.Fail(status.Error(codes.Internal, "boom"))
+	waitTitle(t, tray, ListErrorTitle)
+	if tooltip := tray.Tooltip(); !strings.Contains(tooltip, "boom") {
+		t.Errorf("tooltip %q should tell the error", tooltip)
+	}
+	if item := tray.Find(testLabel("a")); item == nil || !bytes.Equal(item.Icon(), Icon("unknown.png")) {
+		t.Error("session should be marked unknown")
+	}
+
+	fake.Respond(2, testState("a", synchronization.Status_Watching))
+	waitTitle(t, tray, "1-1")
+}
+
+func TestTerminatedSessionDropped(t *testing.T) {
+	tests := []struct {
+		name       string
+		terminated []string
+		title      string
+		selected   []string
+	}{
+		{"one of two", []string{"b"}, "1-1", []string{"a"}},
+		{"all", []string{"a", "b"}, "0-0", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mon, tray, fake := newFakeMonitor(t)
+			mon.config.Selection = SessionFilter{Sessions: []string{"a", "b"}}
+			for _, session := range test.terminated {
+				fake.Forget(session)
+			}
+			// one answer is taken by the probe of a
+			fake.Respond(1, testState("a", synchronization.Status_Watching))
+			fake.Respond(1, testState("a", synchronization.Status_Watching))
+			mon.start(mon.Scheduler)
+			defer mon.Stop()
+			waitTitle(t, tray, test.title)
+			if selection := mon.Selection(); !reflect.DeepEqual(selection.GetSpecifications(), test.selected) {
+				t.Errorf("selection %v, want %v", selection, test.selected)
+			}
+		})
+	}
+}
+
+func TestRefreshBetweenPolls(t *testing.T) {
+	mon, tray, fake := newFakeMonitor(t)
+	// the scheduler is past a poll that returned index 3, a preset switch arrives before the next one
+	mon.stateIndex = 3
+	mon.Refresh()
+	fake.Respond(4, testState("a", synchronization.Status_Watching))
+	mon.start(mon.Scheduler)
+	defer mon.Stop()
+	waitTitle(t, tray, "1-1")
+	if previous := fake.Requests()[0].PreviousStateIndex; previous != 0 {
+		t.Errorf("first poll after refresh waits for changes past %d", previous)
+	}
+}
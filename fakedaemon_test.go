@@ -0,0 +1,152 @@
+package mutagenmon
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	serviceSync "github.com/mutagen-io/mutagen/pkg/service/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// FakeDaemon is an in-process synchronization service answering List with scripted responses.
+// When the script runs out List blocks like a long-poll with no changes.
+type FakeDaemon struct {
+	serviceSync.UnimplementedSynchronizationServer
+
+	lock     sync.Mutex
+	listener *bufconn.Listener
+	server   *grpc.Server
+	script   []fakeList
+	added    chan struct{}
+	requests []*serviceSync.ListRequest
+	connects int
+	missing  map[string]bool
+}
+
+type fakeList struct {
+	response *serviceSync.ListResponse
+	err      error
+}
+
+// NewFakeDaemon starts a fake daemon, Stop it when done
+func NewFakeDaemon() *FakeDaemon {
+	fake := &FakeDaemon{added: make(chan struct{}, 1)}
+	fake.Start()
+	return fake
+}
+
+// Start makes the daemon reachable, again after Stop
+func (self *FakeDaemon) Start() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.listener = bufconn.Listen(1 << 20)
+	self.server = grpc.NewServer()
+	serviceSync.RegisterSynchronizationServer(self.server, self)
+	go self.server.Serve(self.listener)
+}
+
+// Stop drops connections like a daemon that went away
+func (self *FakeDaemon) Stop() {
+	self.lock.Lock()
+	server := self.server
+	self.server = nil
+	self.lock.Unlock()
+	if server != nil {
+		server.Stop()
+	}
+}
+
+// Connect implements SessionSource
+func (self *FakeDaemon) Connect() (*grpc.ClientConn, error) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.server == nil {
+		return nil, fmt.Errorf("no daemon is running")
+	}
+	self.connects++
+	listener := self.listener
+	return grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+}
+
+// Respond queues a list response with state index and sessions
+func (self *FakeDaemon) Respond(index uint64, states ...*synchronization.State) {
+	self.push(fakeList{response: &serviceSync.ListResponse{StateIndex: index, SessionStates: states}})
+}
+
+// Fail queues an error answer
+func (self *FakeDaemon) Fail(err error) {
+	self.push(fakeList{err: err})
+}
+
+func (self *FakeDaemon) push(answer fakeList) {
+	self.lock.Lock()
+	self.script = append(self.script, answer)
+	self.lock.Unlock()
+	select {
+	case self.added <- struct{}{}:
+	default:
+	}
+}
+
+// Forget makes requests naming the session fail with NotFound
+func (self *FakeDaemon) Forget(session string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if self.missing == nil {
+		self.missing = map[string]bool{}
+	}
+	self.missing[session] = true
+}
+
+// Connects tells how many times Connect succeeded
+func (self *FakeDaemon) Connects() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.connects
+}
+
+// Requests returns list requests received so far
+func (self *FakeDaemon) Requests() []*serviceSync.ListRequest {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return append([]*serviceSync.ListRequest{}, self.requests...)
+}
+
+func (self *FakeDaemon) List(ctx context.Context, request *serviceSync.ListRequest) (*serviceSync.ListResponse, error) {
+	self.lock.Lock()
+	self.requests = append(self.requests, request)
+	for _, session := range request.GetSelection().GetSpecifications() {
+		if self.missing[session] {
+			self.lock.Unlock()
+			return nil, status.Errorf(codes.NotFound, "unable to locate requested sessions: %s", session)
+		}
+	}
+	self.lock.Unlock()
+	for {
+		self.lock.Lock()
+		if len(self.script) > 0 {
+			answer := self.script[0]
+			self.script = self.script[1:]
+			self.lock.Unlock()
+			return answer.response, answer.err
+		}
+		self.lock.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		case <-self.added:
+		}
+	}
+}
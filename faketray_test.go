@@ -0,0 +1,172 @@
+package mutagenmon
+
+import (
+	"sync"
+)
+
+// FakeTray keeps the menu in memory so tests can inspect what would be shown
+type FakeTray struct {
+	lock    sync.Mutex
+	title   string
+	tooltip string
+	icon    []byte
+	items   []*FakeItem
+}
+
+// FakeItem is an in-memory menu item
+type FakeItem struct {
+	tray     *FakeTray
+	title    string
+	tooltip  string
+	icon     []byte
+	hidden   bool
+	disabled bool
+	checked  bool
+	children []*FakeItem
+	clicked  chan struct{}
+}
+
+func (self *FakeTray) newItem(title string, tooltip string) *FakeItem {
+	return &FakeItem{tray: self, title: title, tooltip: tooltip, clicked: make(chan struct{})}
+}
+
+func (self *FakeTray) AddMenuItem(title string, tooltip string) MenuItem {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	item := self.newItem(title, tooltip)
+	self.items = append(self.items, item)
+	return item
+}
+
+func (self *FakeTray) AddSeparator() {
+	self.AddMenuItem("---", "")
+}
+
+func (self *FakeTray) SetIcon(icon []byte) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.icon = icon
+}
+
+func (self *FakeTray) SetTitle(title string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.title = title
+}
+
+func (self *FakeTray) SetTooltip(tooltip string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.tooltip = tooltip
+}
+
+func (self *FakeTray) Quit() {}
+
+// Tooltip returns the current tray tooltip
+func (self *FakeTray) Tooltip() string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.tooltip
+}
+
+// Title returns the current tray title
+func (self *FakeTray) Title() string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.title
+}
+
+// Visible returns titles of shown top level items
+func (self *FakeTray) Visible() []string {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return visible(self.items)
+}
+
+func visible(items []*FakeItem) []string {
+	titles := []string{}
+	for _, item := range items {
+		if !item.hidden {
+			titles = append(titles, item.title)
+		}
+	}
+	return titles
+}
+
+// Find returns the last top level item with title
+func (self *FakeTray) Find(title string) *FakeItem {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for i := len(self.items) - 1; i >= 0; i-- {
+		if self.items[i].title == title {
+			return self.items[i]
+		}
+	}
+	return nil
+}
+
+// Icon returns the current item icon
+func (self *FakeItem) Icon() []byte {
+	self.tray.lock.Lock()
+	defer self.tray.lock.Unlock()
+	return self.icon
+}
+
+// Shown tells if the item is visible
+func (self *FakeItem) Shown() bool {
+	self.tray.lock.Lock()
+	defer self.tray.lock.Unlock()
+	return !self.hidden
+}
+
+// Visible returns titles of shown subitems
+func (self *FakeItem) Visible() []string {
+	self.tray.lock.Lock()
+	defer self.tray.lock.Unlock()
+	return visible(self.children)
+}
+
+// Find returns the last subitem with title
+func (self *FakeItem) Find(title string) *FakeItem {
+	self.tray.lock.Lock()
+	defer self.tray.lock.Unlock()
+	for i := len(self.children) - 1; i >= 0; i-- {
+		if self.children[i].title == title {
+			return self.children[i]
+		}
+	}
+	return nil
+}
+
+func (self *FakeItem) AddSubMenuItem(title string, tooltip string) MenuItem {
+	self.tray.lock.Lock()
+	defer self.tray.lock.Unlock()
+	item := self.tray.newItem(title, tooltip)
+	self.children = append(self.children, item)
+	return item
+}
+
+func (self *FakeItem) AddSubMenuItemCheckbox(title string, tooltip string, checked bool) MenuItem {
+	item := self.AddSubMenuItem(title, tooltip)
+	if checked {
+		item.Check()
+	}
+	return item
+}
+
+func (self *FakeItem) set(update func()) {
+	self.tray.lock.Lock()
+	defer self.tray.lock.Unlock()
+	update()
+}
+
+func (self *FakeItem) SetTitle(title string)     { self.set(func() { self.title = title }) }
+func (self *FakeItem) SetTooltip(tooltip string) { self.set(func() { self.tooltip = tooltip }) }
+func (self *FakeItem) SetIcon(icon []byte)       { self.set(func() { self.icon = icon }) }
+func (self *FakeItem) Show()                     { self.set(func() { self.hidden = false }) }
+func (self *FakeItem) Hide()                     { self.set(func() { self.hidden = true }) }
+func (self *FakeItem) Enable()                   { self.set(func() { self.disabled = false }) }
+func (self *FakeItem) Disable()                  { self.set(func() { self.disabled = true }) }
+func (self *FakeItem) Check()                    { self.set(func() { self.checked = true }) }
+func (self *FakeItem) Uncheck()                  { self.set(func() { self.checked = false }) }
+func (self *FakeItem) Clicked() <-chan struct{}  { return self.clicked }
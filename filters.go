@@ -0,0 +1,184 @@
+package mutagenmon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/mutagen-io/mutagen/pkg/selection"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SessionFilter selects sessions on the daemon side, by a label selector or by session names and identifiers
+type SessionFilter struct {
+	LabelSelector string   `yaml:"label_selector"`
+	Sessions      []string `yaml:"sessions"`
+}
+
+// Selection is the daemon selection for the filter, an empty filter selects all sessions
+func (self SessionFilter) Selection() *selection.Selection {
+	if self.LabelSelector == "" && len(self.Sessions) == 0 {
+		return &selection.Selection{All: true}
+	}
+	return &selection.Selection{
+		LabelSelector:  self.LabelSelector,
+		Specifications: self.Sessions,
+	}
+}
+
+func (self SessionFilter) Validate() error {
+	err := self.Selection().EnsureValid()
+	if err != nil {
+		return err
+	}
+	if self.LabelSelector != "" {
+		_, err = selection.ParseLabelSelector(self.LabelSelector)
+		if err != nil {
+			return fmt.Errorf("label_selector: %v", err)
+		}
+	}
+	return nil
+}
+
+func (self SessionFilter) String() string {
+	if self.LabelSelector != "" {
+		return self.LabelSelector
+	}
+	if len(self.Sessions) > 0 {
+		return strings.Join(self.Sessions, ", ")
+	}
+	return "all sessions"
+}
+
+// presetNames lists presets in menu order, the configured selection first as ""
+func (self Config) presetNames() []string {
+	names := []string{""}
+	for name := range self.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// filter returns the named preset, unknown names give the configured selection
+func (self Config) filter(preset string) (string, SessionFilter) {
+	if filter, ok := self.Presets[preset]; ok {
+		return preset, filter
+	}
+	return "", self.Selection
+}
+
+// Selection is the daemon selection of the active preset without sessions that are gone,
+// nil if all the sessions it names are gone
+func (self *MutagenMon) Selection() *selection.Selection {
+	self.configLock.Lock()
+	defer self.configLock.Unlock()
+	_, filter := self.config.filter(self.preset)
+	if len(filter.Sessions) == 0 {
+		return filter.Selection()
+	}
+	var sessions []string
+	for _, session := range filter.Sessions {
+		if !self.missing[session] {
+			sessions = append(sessions, session)
+		}
+	}
+	if len(sessions) == 0 {
+		return nil
+	}
+	filter.Sessions = sessions
+	return filter.Selection()
+}
+
+// dropMissing asks the daemon for each session named by the active preset and drops
+// the ones it does not know from the selection, true if any was dropped
+func (self *MutagenMon) dropMissing(ctx context.Context) bool {
+	self.configLock.Lock()
+	_, filter := self.config.filter(self.preset)
+	self.configLock.Unlock()
+	dropped := false
+	for _, session := range filter.Sessions {
+		probeCtx, cancel := context.WithTimeout(ctx, RequestTimeout)
+		_, _, err := self.WaitSelectedStates(probeCtx, &selection.Selection{Specifications: []string{session}}, 0)
+		cancel()
+		if status.Code(errors.Unwrap(err)) != codes.NotFound {
+			continue
+		}
+		log.Printf("[WARN] session %s is gone, dropped from selection", session)
+		self.configLock.Lock()
+		self.missing[session] = true
+		self.configLock.Unlock()
+		dropped = true
+	}
+	return dropped
+}
+
+// SetPreset switches the sessions listed in the menu to a saved filter preset, "" is the configured selection
+func (self *MutagenMon) SetPreset(name string) {
+	self.configLock.Lock()
+	self.preset = name
+	self.missing = map[string]bool{}
+	self.configLock.Unlock()
+	self.updateFilters()
+	self.Refresh()
+}
+
+// updateFilters shows a checkbox per preset in the filter submenu, hidden when there are no presets
+func (self *MutagenMon) updateFilters() {
+	self.configLock.Lock()
+	config := self.config
+	active, _ := config.filter(self.preset)
+	self.configLock.Unlock()
+
+	self.filterLock.Lock()
+	defer self.filterLock.Unlock()
+	if self.filterMenu == nil {
+		return
+	}
+	if len(config.Presets) == 0 {
+		self.filterMenu.Hide()
+		return
+	}
+	self.filterMenu.Show()
+	label := active
+	if label == "" {
+		label = "default"
+	}
+	self.filterMenu.SetTitle("Filter: " + label)
+	names := config.presetNames()
+	current := map[string]bool{}
+	for _, name := range names {
+		current[name] = true
+		_, filter := config.filter(name)
+		item, ok := self.filterItems[name]
+		if !ok {
+			title := name
+			if title == "" {
+				title = "Default"
+			}
+			item = self.filterMenu.AddSubMenuItemCheckbox(title, "", false)
+			self.filterItems[name] = item
+			go func(name string) {
+				for range item.Clicked() {
+					self.SetPreset(name)
+				}
+			}(name)
+		}
+		item.SetTooltip(filter.String())
+		item.Show()
+		if name == active {
+			item.Check()
+		} else {
+			item.Uncheck()
+		}
+	}
+	for name, item := range self.filterItems {
+		if !current[name] {
+			item.Hide()
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package mutagenmon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/mutagen-io/mutagen/pkg/forwarding"
+	"github.com/mutagen-io/mutagen/pkg/selection"
+	serviceForward "github.com/mutagen-io/mutagen/pkg/service/forwarding"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var forwardConnected = map[forwarding.Status]struct{}{
+	forwarding.Status_ForwardingConnections: {},
+}
+
+var forwardDisconnected = map[forwarding.Status]struct{}{
+	forwarding.Status_Disconnected:          {},
+	forwarding.Status_ConnectingSource:      {},
+	forwarding.Status_ConnectingDestination: {},
+}
+
+type ForwardPeer struct {
+	menu  MenuItem
+	state *forwarding.State
+}
+
+func isForward(state *forwarding.State, scope map[forwarding.Status]struct{}) bool {
+	if state == nil {
+		return false
+	}
+	var ok bool
+	_, ok = scope[state.Status]
+	return ok
+}
+
+// forwardHalted tells if a forward is paused, mutagen will not reconnect it by itself
+func forwardHalted(state *forwarding.State) bool {
+	return state != nil && state.Session != nil && state.Session.Paused
+}
+
+// WaitForwardStates is WaitSessionStates for forwarding sessions
+func (self *MutagenMon) WaitForwardStates(ctx context.Context, previous uint64) (uint64, map[string]*forwarding.State, error) {
+	connection := self.connection()
+	if connection == nil {
+		return 0, nil, ErrNoDaemon
+	}
+	forwardingService := serviceForward.NewForwardingClient(connection)
+	request := &serviceForward.ListRequest{
+		Selection:          &selection.Selection{All: true},
+		PreviousStateIndex: previous,
+	}
+	response, err := forwardingService.List(ctx, request)
+	if err != nil {
+		return 0, nil, fmt.Errorf("get list of mutagen forwards: %w", err)
+	}
+	if response == nil {
+		return 0, nil, fmt.Errorf("empty response")
+	}
+	states := map[string]*forwarding.State{}
+	for _, state := range response.SessionStates {
+		if state == nil || state.Session == nil {
+			continue
+		}
+		states[state.Session.Identifier] = state
+	}
+	return response.StateIndex, states, nil
+}
+
+// ForwardScheduler long-polls forwarding sessions, reconnection is left to Scheduler
+func (self *MutagenMon) ForwardScheduler(ctx context.Context) {
+	for ctx.Err() == nil {
+		pollCtx, cancel, generation := self.pollContext(ctx)
+		if generation != self.forwardRefresh {
+			self.forwardRefresh = generation
+			self.forwardIndex = 0
+			for id, peer := range self.forwards {
+				peer.state = nil
+				self.forwards[id] = peer
+			}
+		}
+		index, states, err := self.WaitForwardStates(pollCtx, self.forwardIndex)
+		refreshed := errors.Is(pollCtx.Err(), context.Canceled)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if refreshed {
+			continue
+		}
+		if status.Code(errors.Unwrap(err)) == codes.DeadlineExceeded {
+			continue
+		}
+		if err != nil {
+			if !daemonLost(err) {
+				log.Printf("[WARN] get forward states: %s", err)
+			}
+			self.forwardIndex = 0
+			for id, peer := range self.forwards {
+				peer.menu.SetIcon(Icon("unknown.png"))
+				peer.state = nil
+				self.forwards[id] = peer
+			}
+			sleep(ctx, self.Config().Interval)
+			continue
+		}
+		self.forwardIndex = index
+		self.CheckForwardStates(states)
+	}
+}
+
+// forwardEndpoints tells where a forward listens and where it connects to
+func forwardEndpoints(session *forwarding.Session) string {
+	return fmt.Sprintf("%s → %s", endpointOf(session.Source), endpointOf(session.Destination))
+}
+
+// forwardTitle is the forward name, or its endpoints if it has none, with open connections
+func forwardTitle(state *forwarding.State) string {
+	label := state.Session.Name
+	if label == "" {
+		label = forwardEndpoints(state.Session)
+	}
+	return fmt.Sprintf("⇄ %s (%d open)", label, state.OpenConnections)
+}
+
+// UpdateMenuItem refreshes forward label and icon
+func (self *ForwardPeer) UpdateMenuItem(state *forwarding.State) {
+	self.menu.SetTitle(forwardTitle(state))
+	if state.LastError != "" {
+		self.menu.SetTooltip(state.LastError)
+	} else {
+		self.menu.SetTooltip(forwardEndpoints(state.Session))
+	}
+	if forwardHalted(state) {
+		self.menu.SetIcon(Icon("fatal.png"))
+	} else if isForward(state, forwardDisconnected) {
+		self.menu.SetIcon(Icon("disconnected.png"))
+	} else if isForward(state, forwardConnected) {
+		self.menu.SetIcon(Icon("ok.png"))
+	} else {
+		self.menu.SetIcon(Icon("unknown.png"))
+	}
+	self.state = state
+}
+
+// shownForwards drops forwards filtered out by config
+func shownForwards(states map[string]*forwarding.State, config Config) map[string]*forwarding.State {
+	filtered := map[string]*forwarding.State{}
+	for id, state := range states {
+		if config.Shown(state.Session.Name, id, forwardTitle(state)) {
+			filtered[id] = state
+		}
+	}
+	return filtered
+}
+
+func (self *MutagenMon) CheckForwardStates(states map[string]*forwarding.State) {
+	states = shownForwards(states, self.Config())
+	for id, current := range states {
+		peer, ok := self.forwards[id]
+		if !ok {
+			peer = ForwardPeer{menu: self.tray.AddMenuItem(forwardTitle(current), "")}
+			peer.UpdateMenuItem(current)
+			self.forwards[id] = peer
+			continue
+		}
+		if peer.state == nil || peer.state.Status != current.Status || peer.state.OpenConnections != current.OpenConnections ||
+			peer.state.Session.Paused != current.Session.Paused || peer.state.LastError != current.LastError {
+			peer.UpdateMenuItem(current)
+			self.forwards[id] = peer
+		}
+	}
+	for id, peer := range self.forwards {
+		_, ok := states[id]
+		if !ok {
+			peer.menu.Hide()
+			delete(self.forwards, id)
+		}
+	}
+	self.titleLock.Lock()
+	self.forwardStates = states
+	self.titleLock.Unlock()
+	self.UpdateTitle()
+}
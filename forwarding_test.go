@@ -0,0 +1,33 @@
+package mutagenmon
+
+import (
+	"testing"
+
+	"github.com/mutagen-io/mutagen/pkg/forwarding"
+	"github.com/mutagen-io/mutagen/pkg/url"
+)
+
+func TestForwardTitle(t *testing.T) {
+	local := &url.URL{Kind: url.Kind_Forwarding, Protocol: url.Protocol_Local, Path: "tcp:localhost:5432"}
+	tests := []struct {
+		name    string
+		session *forwarding.Session
+		want    string
+	}{
+		{"local to ssh", &forwarding.Session{
+			Source:      local,
+			Destination: &url.URL{Kind: url.Kind_Forwarding, Protocol: url.Protocol_SSH, User: "me", Host: "db", Path: "tcp:localhost:5432"},
+		}, "⇄ tcp:localhost:5432 → ssh:me@db:tcp:localhost:5432 (2 open)"},
+		{"docker to local", &forwarding.Session{
+			Source:      &url.URL{Kind: url.Kind_Forwarding, Protocol: url.Protocol_Docker, Host: "web", Path: "tcp::8080"},
+			Destination: local,
+		}, "⇄ docker://web:tcp::8080 → tcp:localhost:5432 (2 open)"},
+		{"named", &forwarding.Session{Name: "postgres", Source: local, Destination: local}, "⇄ postgres (2 open)"},
+	}
+	for _, test := range tests {
+		got := forwardTitle(&forwarding.State{Session: test.session, OpenConnections: 2})
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package mutagenmon
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+const (
+	GroupByHost   = "host"
+	GroupByLabel  = "label:"
+	GroupByPrefix = "prefix:"
+)
+
+// severity orders categories for group icons, the worst member wins
+var severity = map[Category]int{
+	CategoryWatching:     0,
+	CategorySyncing:      1,
+	CategoryUnknown:      2,
+	CategoryDisconnected: 3,
+	CategoryFatal:        4,
+}
+
+// SessionGroup is a submenu holding sessions with the same group key
+type SessionGroup struct {
+	menu  MenuItem
+	label string
+	icon  string
+}
+
+func validGroupBy(groupBy string) error {
+	switch {
+	case groupBy == "", groupBy == GroupByHost:
+		return nil
+	case strings.HasPrefix(groupBy, GroupByLabel) && len(groupBy) > len(GroupByLabel):
+		return nil
+	case strings.HasPrefix(groupBy, GroupByPrefix) && len(groupBy) > len(GroupByPrefix):
+		return nil
+	}
+	return fmt.Errorf("group_by must be empty, %s, %s<key> or %s<separator>", GroupByHost, GroupByLabel, GroupByPrefix)
+}
+
+// GroupOf returns the group a session belongs to, empty for top level sessions
+func (self Config) GroupOf(state *synchronization.State) string {
+	session := state.Session
+	switch {
+	case self.GroupBy == GroupByHost:
+		if session.Beta.Host == "" {
+			return "localhost"
+		}
+		return session.Beta.Host
+	case strings.HasPrefix(self.GroupBy, GroupByLabel):
+		return session.Labels[strings.TrimPrefix(self.GroupBy, GroupByLabel)]
+	case strings.HasPrefix(self.GroupBy, GroupByPrefix):
+		separator := strings.TrimPrefix(self.GroupBy, GroupByPrefix)
+		if i := strings.Index(session.Name, separator); i > 0 {
+			return session.Name[:i]
+		}
+	}
+	return ""
+}
+
+// stateIcon returns the icon for a category, conflicts are shown as a badge on non-bad states
+func stateIcon(category Category, conflicts bool) []byte {
+	var name string
+	switch category {
+	case CategoryDisconnected:
+		return Icon("disconnected.png")
+	case CategoryFatal:
+		return Icon("fatal.png")
+	case CategorySyncing:
+		name = "syncing.png"
+	case CategoryWatching:
+		name = "ok.png"
+	default:
+		name = "unknown.png"
+	}
+	if conflicts {
+		return icons.Badged(name, "conflict.png")
+	}
+	return Icon(name)
+}
+
+// worst returns the most severe category of states and whether any of them has conflicts
+func worst(states []*synchronization.State, classes Classification) (Category, bool) {
+	category := CategoryWatching
+	conflicts := false
+	for _, state := range states {
+		if current := classes.Of(state); severity[current] > severity[category] {
+			category = current
+		}
+		if hasConflicts(state) && !classes.Bad(state) {
+			conflicts = true
+		}
+	}
+	return category, conflicts
+}
+
+// sessionItem adds a menu item for a session, inside its group submenu if it has one
+func (self *MutagenMon) sessionItem(group string, title string) MenuItem {
+	if group == "" {
+		return self.tray.AddMenuItem(title, "")
+	}
+	parent, ok := self.groups[group]
+	if !ok {
+		parent = &SessionGroup{menu: self.tray.AddMenuItem(group, "")}
+		self.groups[group] = parent
+	}
+	parent.menu.Show()
+	return parent.menu.AddSubMenuItem(title, "")
+}
+
+// updateGroups sets group labels with healthy and total counts and icons of the worst member
+func (self *MutagenMon) updateGroups(states map[string]*synchronization.State, config Config) {
+	members := map[string]map[string]*synchronization.State{}
+	for id, state := range states {
+		group := config.GroupOf(state)
+		if group == "" {
+			continue
+		}
+		if members[group] == nil {
+			members[group] = map[string]*synchronization.State{}
+		}
+		members[group][id] = state
+	}
+	for name, group := range self.groups {
+		current, ok := members[name]
+		if !ok {
+			group.menu.Hide()
+			continue
+		}
+		group.menu.Show()
+		summary := Summarize(current, config.Classification)
+		label := fmt.Sprintf("%s (%d/%d)", name, summary.Healthy, summary.Total)
+		if label != group.label {
+			group.menu.SetTitle(label)
+			group.label = label
+		}
+		list := make([]*synchronization.State, 0, len(current))
+		for _, state := range current {
+			list = append(list, state)
+		}
+		category, conflicts := worst(list, config.Classification)
+		icon := fmt.Sprintf("%s/%v", category, conflicts)
+		if icon != group.icon {
+			group.menu.SetIcon(stateIcon(category, conflicts))
+			group.icon = icon
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package mutagenmon
+
+import (
+	"testing"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/synchronization/core"
+	"github.com/mutagen-io/mutagen/pkg/url"
+)
+
+func TestGroupOf(t *testing.T) {
+	state := &synchronization.State{Session: &synchronization.Session{
+		Name:   "web-assets",
+		Beta:   &url.URL{Host: "build"},
+		Labels: map[string]string{"project": "shop"},
+	}}
+	tests := []struct {
+		groupBy string
+		want    string
+	}{
+		{"", ""},
+		{"host", "build"},
+		{"label:project", "shop"},
+		{"label:team", ""},
+		{"prefix:-", "web"},
+		{"prefix:/", ""},
+	}
+	for _, test := range tests {
+		config := DefaultConfig()
+		config.GroupBy = test.groupBy
+		if err := config.Validate(); err != nil {
+			t.Fatalf("%q: %v", test.groupBy, err)
+		}
+		if got := config.GroupOf(state); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.groupBy, got, test.want)
+		}
+	}
+	config := DefaultConfig()
+	config.GroupBy = "label:"
+	if config.Validate() == nil {
+		t.Error("label without key should be rejected")
+	}
+}
+
+func TestWorst(t *testing.T) {
+	classes := DefaultClassification()
+	watching := &synchronization.State{Status: synchronization.Status_Watching}
+	conflicted := &synchronization.State{Status: synchronization.Status_Watching, Conflicts: make([]*core.Conflict, 1)}
+	halted := &synchronization.State{Status: synchronization.Status_HaltedOnRootDeletion}
+
+	category, conflicts := worst([]*synchronization.State{watching, conflicted}, classes)
+	if category != CategoryWatching || !conflicts {
+		t.Errorf("got %s %v", category, conflicts)
+	}
+	category, _ = worst([]*synchronization.State{watching, halted}, classes)
+	if category != CategoryFatal {
+		t.Errorf("got %s", category)
+	}
+}
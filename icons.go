@@ -0,0 +1,240 @@
+package mutagenmon
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed MutagenMon.app/Contents/Resources/*.png
+var embeddedIcons embed.FS
+
+const embeddedIconDir = "MutagenMon.app/Contents/Resources"
+
+// IconNames are all icons the monitor uses
+var IconNames = []string{"icon.png", "ok.png", "syncing.png", "conflict.png", "disconnected.png", "fatal.png", "unknown.png"}
+
+type icon struct {
+	data  []byte
+	image image.Image
+}
+
+// Icons keeps decoded icons in memory, override directories take precedence over embedded icons
+type Icons struct {
+	lock        sync.RWMutex
+	dirs        []string
+	icons       map[string]icon
+	composites  map[string][]byte
+	fingerprint string
+}
+
+var icons = NewIcons(ThemeIconDir())
+
+func NewIcons(dirs ...string) *Icons {
+	icons := &Icons{}
+	icons.SetDirs(dirs...)
+	return icons
+}
+
+// ThemeIconDir is mutagenmon under the XDG icons directory, e.g. ~/.local/share/icons/mutagenmon
+func ThemeIconDir() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "icons", "mutagenmon")
+}
+
+func setIconDir(path string) {
+	icons.SetDirs(path, ThemeIconDir())
+}
+
+func decodeIcon(data []byte) (icon, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return icon{}, err
+	}
+	return icon{data: data, image: img}, nil
+}
+
+// SetDirs sets override directories in order of precedence and reloads icons
+func (self *Icons) SetDirs(dirs ...string) {
+	self.lock.Lock()
+	self.dirs = nil
+	for _, dir := range dirs {
+		if dir != "" {
+			self.dirs = append(self.dirs, dir)
+		}
+	}
+	self.lock.Unlock()
+	self.Load()
+}
+
+// Load reads and validates all icons, broken overrides are logged and skipped
+func (self *Icons) Load() {
+	self.lock.RLock()
+	dirs := self.dirs
+	self.lock.RUnlock()
+
+	loaded := map[string]icon{}
+	for _, name := range IconNames {
+		for _, dir := range dirs {
+			file := filepath.Join(dir, name)
+			data, err := os.ReadFile(file)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				log.Printf("[WARN] read icon %s: %s", file, err)
+				continue
+			}
+			decoded, err := decodeIcon(data)
+			if err != nil {
+				log.Printf("[WARN] icon %s is not a valid png: %s", file, err)
+				continue
+			}
+			log.Printf("[DEBUG] icon %s loaded from %s", name, dir)
+			loaded[name] = decoded
+			break
+		}
+		if _, ok := loaded[name]; ok {
+			continue
+		}
+		data, err := embeddedIcons.ReadFile(path.Join(embeddedIconDir, name))
+		if err == nil {
+			var decoded icon
+			decoded, err = decodeIcon(data)
+			if err == nil {
+				loaded[name] = decoded
+				continue
+			}
+		}
+		log.Printf("[WARN] icon %s is missing: %s", name, err)
+	}
+
+	fingerprint := iconFingerprint(dirs)
+	self.lock.Lock()
+	self.icons = loaded
+	self.composites = map[string][]byte{}
+	self.fingerprint = fingerprint
+	self.lock.Unlock()
+}
+
+// iconFingerprint changes whenever an icon file in dirs is added, removed or modified
+func iconFingerprint(dirs []string) string {
+	var fingerprint strings.Builder
+	for _, dir := range dirs {
+		for _, name := range IconNames {
+			info, err := os.Stat(filepath.Join(dir, name))
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(&fingerprint, "%s/%s:%d:%d;", dir, name, info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return fingerprint.String()
+}
+
+// Changed tells if icon files changed since the last Load
+func (self *Icons) Changed() bool {
+	self.lock.RLock()
+	dirs := self.dirs
+	fingerprint := self.fingerprint
+	self.lock.RUnlock()
+	return iconFingerprint(dirs) != fingerprint
+}
+
+func (self *Icons) Get(name string) []byte {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if icon, ok := self.icons[name]; ok {
+		return icon.data
+	}
+	return defaultIcon
+}
+
+// Badged renders icon name with badge scaled to the bottom right quarter, results are cached
+func (self *Icons) Badged(name string, badge string) []byte {
+	key := name + "+" + badge
+	self.lock.RLock()
+	cached, ok := self.composites[key]
+	base, baseOk := self.icons[name]
+	overlay, overlayOk := self.icons[badge]
+	self.lock.RUnlock()
+	if ok {
+		return cached
+	}
+	if !baseOk || !overlayOk {
+		return self.Get(badge)
+	}
+
+	bounds := base.image.Bounds()
+	canvas := image.NewRGBA(bounds)
+	draw.Draw(canvas, bounds, base.image, bounds.Min, draw.Src)
+	size := bounds.Dx() / 2
+	corner := image.Rect(bounds.Max.X-size, bounds.Max.Y-size, bounds.Max.X, bounds.Max.Y)
+	draw.Draw(canvas, corner, scale(overlay.image, size), image.Point{}, draw.Over)
+	var out bytes.Buffer
+	err := png.Encode(&out, canvas)
+	if err != nil {
+		log.Printf("[WARN] render %s: %s", key, err)
+		return self.Get(badge)
+	}
+
+	self.lock.Lock()
+	self.composites[key] = out.Bytes()
+	self.lock.Unlock()
+	return out.Bytes()
+}
+
+// scale resizes img to a size x size square with nearest neighbour sampling
+func scale(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	scaled := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			scaled.Set(x, y, img.At(bounds.Min.X+x*bounds.Dx()/size, bounds.Min.Y+y*bounds.Dy()/size))
+		}
+	}
+	return scaled
+}
+
+// WatchIcons reloads icons when files in icon directories change
+func (self *MutagenMon) WatchIcons(ctx context.Context) {
+	ticker := time.NewTicker(ConfigCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !icons.Changed() {
+			continue
+		}
+		log.Printf("[INFO] icon files changed, reloading")
+		icons.Load()
+		self.RedrawTrayIcon()
+		self.Refresh()
+	}
+}
+
+// Icon returns a cached icon by file name
+func Icon(name string) []byte {
+	return icons.Get(name)
+}
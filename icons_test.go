@@ -0,0 +1,70 @@
+package mutagenmon
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIconsOverride(t *testing.T) {
+	dir := t.TempDir()
+	var override bytes.Buffer
+	err := png.Encode(&override, image.NewRGBA(image.Rect(0, 0, 8, 8)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "ok.png"), override.Bytes(), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "fatal.png"), []byte("not a png"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewIcons(dir)
+	if !bytes.Equal(registry.Get("ok.png"), override.Bytes()) {
+		t.Error("valid override is not used")
+	}
+	embedded := NewIcons()
+	if !bytes.Equal(registry.Get("fatal.png"), embedded.Get("fatal.png")) {
+		t.Error("corrupt override should fall back to embedded icon")
+	}
+	if registry.Changed() {
+		t.Error("nothing changed since load")
+	}
+	err = os.WriteFile(filepath.Join(dir, "unknown.png"), override.Bytes(), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !registry.Changed() {
+		t.Error("new icon file is not noticed")
+	}
+}
+
+func TestIconsBadged(t *testing.T) {
+	registry := NewIcons()
+	for _, name := range IconNames {
+		if bytes.Equal(registry.Get(name), defaultIcon) {
+			t.Errorf("icon %s is not embedded", name)
+		}
+	}
+	badged := registry.Badged("ok.png", "conflict.png")
+	img, err := png.Decode(bytes.NewReader(badged))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := png.Decode(bytes.NewReader(registry.Get("ok.png")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != base.Bounds() {
+		t.Errorf("badged icon is %v, expected %v", img.Bounds(), base.Bounds())
+	}
+	if !bytes.Equal(badged, registry.Badged("ok.png", "conflict.png")) {
+		t.Error("badged icon is not cached")
+	}
+}
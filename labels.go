@@ -0,0 +1,102 @@
+package mutagenmon
+
+import (
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/synchronization/core"
+	"github.com/mutagen-io/mutagen/pkg/url"
+)
+
+const DefaultSessionLabel = `{{if .Name}}{{.Name}}{{else}}{{.Alpha}} → {{.Beta}}{{end}}`
+
+const DefaultSessionTooltip = `{{.Alpha}} ⇄ {{.Beta}}` + "\n" +
+	`{{.Mode}}, created {{.Created.Format "2006-01-02 15:04"}}`
+
+// Endpoint is one side of a session in label templates
+type Endpoint struct {
+	Protocol string // local, ssh or docker
+	User     string
+	Host     string
+	Port     uint32
+	Path     string
+	URL      string // as mutagen prints it
+}
+
+// String is the URL, SSH URLs get a prefix as they look like local paths otherwise
+func (self Endpoint) String() string {
+	if self.Protocol == "ssh" {
+		return "ssh:" + self.URL
+	}
+	return self.URL
+}
+
+// SessionInfo is what session_label and session_tooltip templates are rendered with
+type SessionInfo struct {
+	Identifier string
+	Name       string
+	Alpha      Endpoint
+	Beta       Endpoint
+	Mode       string // e.g. two-way-safe
+	Created    time.Time
+	Status     string
+	Conflicts  int
+	Labels     map[string]string
+}
+
+func endpointOf(location *url.URL) Endpoint {
+	if location == nil {
+		return Endpoint{}
+	}
+	endpoint := Endpoint{
+		Protocol: strings.ToLower(location.Protocol.String()),
+		User:     location.User,
+		Host:     location.Host,
+		Port:     location.Port,
+		Path:     location.Path,
+	}
+	switch location.Protocol {
+	case url.Protocol_Local, url.Protocol_SSH, url.Protocol_Docker:
+		endpoint.URL = location.Format("")
+	default:
+		endpoint.URL = location.Path
+	}
+	return endpoint
+}
+
+// sessionInfo collects session details for templates
+func sessionInfo(state *synchronization.State) SessionInfo {
+	session := state.Session
+	mode := core.SynchronizationMode_SynchronizationModeTwoWaySafe
+	if session.Configuration != nil && !session.Configuration.SynchronizationMode.IsDefault() {
+		mode = session.Configuration.SynchronizationMode
+	}
+	modeName, _ := mode.MarshalText()
+	var created time.Time
+	if session.CreationTime != nil {
+		created = session.CreationTime.AsTime().Local()
+	}
+	return SessionInfo{
+		Identifier: session.Identifier,
+		Name:       session.Name,
+		Alpha:      endpointOf(session.Alpha),
+		Beta:       endpointOf(session.Beta),
+		Mode:       string(modeName),
+		Created:    created,
+		Status:     statusName(state.Status),
+		Conflicts:  len(state.Conflicts),
+		Labels:     session.Labels,
+	}
+}
+
+// Render executes a session label or tooltip template
+func (self SessionInfo) Render(tmpl *template.Template) (string, error) {
+	var out strings.Builder
+	err := tmpl.Execute(&out, self)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
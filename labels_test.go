@@ -0,0 +1,66 @@
+package mutagenmon
+
+import (
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/synchronization/core"
+	"github.com/mutagen-io/mutagen/pkg/url"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSessionLabel(t *testing.T) {
+	created := time.Date(2023, 5, 1, 12, 0, 0, 0, time.Local)
+	state := &synchronization.State{
+		Status: synchronization.Status_Watching,
+		Session: &synchronization.Session{
+			Identifier:   "sync_1",
+			Alpha:        &url.URL{Protocol: url.Protocol_Local, Path: "/home/me/web"},
+			Beta:         &url.URL{Protocol: url.Protocol_SSH, User: "me", Host: "build", Path: "/srv/web"},
+			CreationTime: timestamppb.New(created),
+			Configuration: &synchronization.Configuration{
+				SynchronizationMode: core.SynchronizationMode_SynchronizationModeOneWayReplica,
+			},
+		},
+	}
+	config := DefaultConfig()
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"unnamed", DefaultSessionLabel, "/home/me/web → ssh:me@build:/srv/web"},
+		{"tooltip", DefaultSessionTooltip, "/home/me/web ⇄ ssh:me@build:/srv/web\none-way-replica, created 2023-05-01 12:00"},
+		{"fields", "{{.Beta.Protocol}} {{.Status}} {{.Identifier}}", "ssh watching sync_1"},
+	}
+	for _, test := range tests {
+		tmpl, err := parseSessionLabel(test.name, test.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := sessionInfo(state).Render(tmpl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+	state.Session.Beta = &url.URL{Protocol: url.Protocol_Docker, User: "node", Host: "web", Path: "/srv/web"}
+	got, err := sessionInfo(state).Render(template.Must(template.New("docker").Parse("{{.Beta}} {{.Beta.Protocol}}")))
+	if err != nil || got != "docker://node@web/srv/web docker" {
+		t.Errorf("docker: got %q, %v", got, err)
+	}
+
+	state.Session.Name = "web"
+	got, err = sessionInfo(state).Render(config.SessionLabelTemplate)
+	if err != nil || got != "web" {
+		t.Errorf("named: got %q, %v", got, err)
+	}
+	state.Session.Configuration = nil
+	if mode := sessionInfo(state).Mode; mode != "two-way-safe" {
+		t.Errorf("default mode: got %s", mode)
+	}
+}
@@ -0,0 +1,160 @@
+package mutagenmon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+const ScrapeTimeout = 10 * time.Second
+
+// metrics is a minimal writer of the Prometheus text exposition format
+type metrics struct {
+	out     io.Writer
+	written map[string]struct{}
+}
+
+func (self *metrics) write(name string, kind string, help string, labels [][2]string, value float64) {
+	if _, ok := self.written[name]; !ok {
+		fmt.Fprintf(self.out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+		self.written[name] = struct{}{}
+	}
+	pairs := make([]string, 0, len(labels))
+	for _, label := range labels {
+		value := strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(label[1])
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label[0], value))
+	}
+	fmt.Fprintf(self.out, "%s{%s} %g\n", name, strings.Join(pairs, ","), value)
+}
+
+func gaugeBool(value bool) float64 {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+func statusName(status synchronization.Status) string {
+	name, err := status.MarshalText()
+	if err != nil {
+		return status.String()
+	}
+	return string(name)
+}
+
+// WriteMetrics writes per-session gauges for states, metrics are grouped by name
+func WriteMetrics(out io.Writer, states map[string]*synchronization.State) {
+	ids := make([]string, 0, len(states))
+	for id := range states {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	m := &metrics{out: out, written: map[string]struct{}{}}
+	sessionLabels := func(state *synchronization.State) [][2]string {
+		return [][2]string{
+			{"session", state.Session.Identifier},
+			{"name", state.Session.Name},
+			{"alpha", state.Session.Alpha.Format("")},
+			{"beta", state.Session.Beta.Format("")},
+		}
+	}
+	statuses := make([]int, 0, len(synchronization.Status_name))
+	for value := range synchronization.Status_name {
+		statuses = append(statuses, int(value))
+	}
+	sort.Ints(statuses)
+	for _, id := range ids {
+		state := states[id]
+		for _, value := range statuses {
+			status := synchronization.Status(value)
+			labels := append(sessionLabels(state), [2]string{"status", statusName(status)})
+			m.write("mutagen_session_status", "gauge", "Current session status, 1 for the active one.", labels, gaugeBool(state.Status == status))
+		}
+	}
+	for _, id := range ids {
+		state := states[id]
+		m.write("mutagen_session_conflicts", "gauge", "Number of conflicts reported by the session.", sessionLabels(state), float64(len(state.Conflicts)+int(state.ExcludedConflicts)))
+	}
+	for _, id := range ids {
+		state := states[id]
+		m.write("mutagen_session_successful_cycles_total", "counter", "Number of successful synchronization cycles.", sessionLabels(state), float64(state.SuccessfulCycles))
+	}
+	for _, id := range ids {
+		state := states[id]
+		m.write("mutagen_session_paused", "gauge", "Whether the session is paused.", sessionLabels(state), gaugeBool(state.Session.Paused))
+	}
+
+	type endpointMetric struct {
+		name  string
+		help  string
+		value func(*synchronization.EndpointState) float64
+	}
+	endpointMetrics := []endpointMetric{
+		{"mutagen_endpoint_connected", "Whether the endpoint is connected.", func(e *synchronization.EndpointState) float64 { return gaugeBool(e.GetConnected()) }},
+		{"mutagen_endpoint_scanned", "Whether the endpoint has been scanned.", func(e *synchronization.EndpointState) float64 { return gaugeBool(e.GetScanned()) }},
+		{"mutagen_endpoint_files", "Number of synchronizable files.", func(e *synchronization.EndpointState) float64 { return float64(e.GetFiles()) }},
+		{"mutagen_endpoint_directories", "Number of synchronizable directories.", func(e *synchronization.EndpointState) float64 { return float64(e.GetDirectories()) }},
+		{"mutagen_endpoint_symbolic_links", "Number of synchronizable symbolic links.", func(e *synchronization.EndpointState) float64 { return float64(e.GetSymbolicLinks()) }},
+		{"mutagen_endpoint_total_file_size_bytes", "Total size of synchronizable files.", func(e *synchronization.EndpointState) float64 { return float64(e.GetTotalFileSize()) }},
+		{"mutagen_endpoint_staging_received_files", "Files received in the current staging operation.", func(e *synchronization.EndpointState) float64 {
+			return float64(e.GetStagingProgress().GetReceivedFiles())
+		}},
+		{"mutagen_endpoint_staging_expected_files", "Files expected in the current staging operation.", func(e *synchronization.EndpointState) float64 {
+			return float64(e.GetStagingProgress().GetExpectedFiles())
+		}},
+		{"mutagen_endpoint_staging_received_bytes", "Bytes received in the current staging operation.", func(e *synchronization.EndpointState) float64 {
+			return float64(e.GetStagingProgress().GetTotalReceivedSize())
+		}},
+	}
+	for _, metric := range endpointMetrics {
+		for _, id := range ids {
+			state := states[id]
+			for _, endpoint := range []struct {
+				name  string
+				state *synchronization.EndpointState
+			}{{"alpha", state.AlphaState}, {"beta", state.BetaState}} {
+				labels := append(sessionLabels(state), [2]string{"endpoint", endpoint.name})
+				m.write(metric.name, "gauge", metric.help, labels, metric.value(endpoint.state))
+			}
+		}
+	}
+}
+
+func (self *MutagenMon) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), ScrapeTimeout)
+	defer cancel()
+	connection := self.connection()
+	states, err := self.SessionStates(ctx)
+	// a concurrent scrape may have replaced and closed the connection while this one used it
+	if daemonLost(err) || (err != nil && self.connection() != connection) {
+		log.Printf("[WARN] lost mutagen daemon: %s", err)
+		err = self.redial(connection)
+		if err == nil {
+			states, err = self.SessionStates(ctx)
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err != nil {
+		log.Printf("[WARN] get states: %s", err)
+		fmt.Fprintf(w, "# HELP mutagen_daemon_up Whether the mutagen daemon answered.\n# TYPE mutagen_daemon_up gauge\nmutagen_daemon_up 0\n")
+		return
+	}
+	fmt.Fprintf(w, "# HELP mutagen_daemon_up Whether the mutagen daemon answered.\n# TYPE mutagen_daemon_up gauge\nmutagen_daemon_up 1\n")
+	WriteMetrics(w, states)
+}
+
+// ServeMetrics runs without tray and serves /metrics on addr
+func (self *MutagenMon) ServeMetrics(addr string) error {
+	log.Printf("[INFO] Mutagenmon metrics on %s/metrics", addr)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", self.metricsHandler)
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,41 @@
+package mutagenmon
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/url"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files in testdata")
+
+func TestWriteMetrics(t *testing.T) {
+	state := testState("sync_1", synchronization.Status_StagingBeta, "x")
+	state.Session.Name = `web "main"`
+	state.Session.Alpha = &url.URL{Protocol: url.Protocol_Local, Path: `C:\src\web` + "\nnext"}
+	state.ExcludedConflicts = 2
+	state.SuccessfulCycles = 7
+	state.AlphaState = &synchronization.EndpointState{Connected: true, Scanned: true, Files: 3, Directories: 1, TotalFileSize: 1024}
+
+	var out bytes.Buffer
+	WriteMetrics(&out, testStates(state))
+
+	golden := filepath.Join("testdata", "metrics.golden")
+	if *update {
+		err := os.WriteFile(golden, out.Bytes(), 0o644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("metrics differ from %s, run go test -update if the change is intended:\n%s", golden, out.String())
+	}
+}
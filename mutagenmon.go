@@ -2,110 +2,168 @@ package mutagenmon
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
-	"path/filepath"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
 	"time"
 
 	"fyne.io/systray"
-	"github.com/mutagen-io/mutagen/cmd/mutagen/daemon"
-	daemon2 "github.com/mutagen-io/mutagen/pkg/daemon"
+	"github.com/mutagen-io/mutagen/pkg/forwarding"
 	"github.com/mutagen-io/mutagen/pkg/selection"
 	serviceSync "github.com/mutagen-io/mutagen/pkg/service/synchronization"
 	"github.com/mutagen-io/mutagen/pkg/synchronization"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const InitInterval = 2 * time.Second
 
-var fatal = map[synchronization.Status]struct{}{
-	synchronization.Status_HaltedOnRootEmptied:    {},
-	synchronization.Status_HaltedOnRootDeletion:   {},
-	synchronization.Status_HaltedOnRootTypeChange: {},
-}
-
-var disconnected = map[synchronization.Status]struct{}{
-	synchronization.Status_Disconnected:   {},
-	synchronization.Status_ConnectingBeta: {},
-}
+// LivenessInterval bounds a single long-poll of the daemon, so the connection
+// is re-checked even when no session changes for a long time.
+const LivenessInterval = 30 * time.Second
 
-var watching = map[synchronization.Status]struct{}{
-	synchronization.Status_WaitingForRescan: {},
-	synchronization.Status_Watching:         {},
-}
+// RequestTimeout bounds daemon requests that don't wait for changes
+const RequestTimeout = 10 * time.Second
 
-var syncing = map[synchronization.Status]struct{}{
-	synchronization.Status_ConnectingAlpha: {},
-	synchronization.Status_Scanning:        {},
-	synchronization.Status_Reconciling:     {},
-	synchronization.Status_StagingAlpha:    {},
-	synchronization.Status_StagingBeta:     {},
-	synchronization.Status_Transitioning:   {},
-	synchronization.Status_Saving:          {},
-}
+// ShutdownTimeout is how long Stop waits for schedulers to return
+const ShutdownTimeout = 5 * time.Second
 
 type Peer struct {
-	menu  *systray.MenuItem
+	mon   *MutagenMon
+	menu  MenuItem
 	state *synchronization.State
 	//callback  chan struct{} // not used as for now
-	conflicts map[string]*systray.MenuItem
+	conflicts map[string]MenuItem
+	more      MenuItem
+
+	problems     MenuItem
+	problemItems map[string]MenuItem
+
+	label   string
+	tooltip string
+	staging *staging
+	group   string
 }
 
 type MutagenMon struct {
-	peers     map[string]Peer
-	callbacks map[string]chan struct{} // not used as for now
-	daemon    *grpc.ClientConn
-	interval  time.Duration
-	bad       int
-	conflict  int
-	total     int
-	sync      string
-}
+	tray         Tray
+	source       SessionSource
+	ctx          context.Context // root context, cancelled by Stop
+	cancel       context.CancelFunc
+	workers      sync.WaitGroup
+	peers        map[string]Peer
+	callbacks    map[string]chan struct{} // not used as for now
+	daemon       *grpc.ClientConn
+	daemonLock   sync.Mutex // guards daemon and startItem, menu actions use them from their own goroutines
+	startItem    MenuItem
+	redialLock   sync.Mutex // one reconnection at a time
+	stateIndex   uint64
+	notifier     *Notifier
+	forwards     map[string]ForwardPeer
+	forwardIndex uint64
+	groups       map[string]*SessionGroup
 
-func is(state *synchronization.State, scope map[synchronization.Status]struct{}) bool {
-	if state == nil {
-		return false
-	}
-	var ok bool
-	_, ok = scope[state.Status]
-	return ok
+	configLock     sync.Mutex
+	config         Config
+	refreshLock    sync.Mutex
+	refreshCtx     context.Context
+	refreshCancel  context.CancelFunc
+	refreshes      uint64 // refresh generation, guarded by refreshLock
+	stateRefresh   uint64 // refresh generations seen by Scheduler and ForwardScheduler
+	forwardRefresh uint64
+	preset         string          // guarded by configLock
+	missing        map[string]bool // guarded by configLock, selected session names the daemon does not know
+
+	filterLock  sync.Mutex
+	filterMenu  MenuItem
+	filterItems map[string]MenuItem
+
+	titleLock     sync.Mutex // guards fields below, they are updated by both schedulers
+	summary       Summary
+	forwardStates map[string]*forwarding.State
+	title         string
+	tooltip       string
+	trayIcon      string
 }
 
+// New creates a monitor of the local daemon. It is usable even if the daemon is not running:
+// the error tells why it could not connect and the monitor connects once the daemon is started.
 func New() (*MutagenMon, error) {
-	lock, err := daemon2.AcquireLock()
-	if err == nil {
-		// should not be here if daemon is running
-		err2 := lock.Release()
-		if err2 != nil {
-			panic(err2)
-		}
-		return nil, fmt.Errorf("no daemon is running")
-	}
-	connection, err := daemon.Connect(true, false)
+	source := DaemonSource{}
+	connection, connectErr := source.Connect()
+	mutagenMon := newMonitor(SystrayTray{}, source, connection)
+	config, err := LoadConfig(ConfigPath())
 	if err != nil {
-		return nil, fmt.Errorf("connect to mutagen daemon: %v", err)
+		log.Printf("[WARN] using default config: %s", err)
 	}
+	mutagenMon.setConfig(config)
+	return mutagenMon, connectErr
+}
+
+// newMonitor creates a monitor drawing on tray with default config, connection may be nil until redial
+func newMonitor(tray Tray, source SessionSource, connection *grpc.ClientConn) *MutagenMon {
 	mutagenMon := MutagenMon{
-		peers:    map[string]Peer{},
-		daemon:   connection,
-		interval: InitInterval,
+		tray:        tray,
+		source:      source,
+		peers:       map[string]Peer{},
+		forwards:    map[string]ForwardPeer{},
+		groups:      map[string]*SessionGroup{},
+		filterItems: map[string]MenuItem{},
+		missing:     map[string]bool{},
+		notifier:    NewNotifier(),
+		daemon:      connection,
+		config:      DefaultConfig(),
 	}
-	return &mutagenMon, nil
+	mutagenMon.ctx, mutagenMon.cancel = context.WithCancel(context.Background())
+	mutagenMon.refreshCtx, mutagenMon.refreshCancel = context.WithCancel(context.Background())
+	return &mutagenMon
 }
 
+// SessionStates returns current states of selected sessions without waiting for changes.
 func (self *MutagenMon) SessionStates(ctx context.Context) (map[string]*synchronization.State, error) {
-	synchronizationService := serviceSync.NewSynchronizationClient(self.daemon)
+	ctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+	_, states, err := self.WaitSessionStates(ctx, 0)
+	return states, err
+}
+
+// WaitSessionStates blocks until the daemon state index moves past previous
+// (0 returns immediately) and returns the new index with session states.
+func (self *MutagenMon) WaitSessionStates(ctx context.Context, previous uint64) (uint64, map[string]*synchronization.State, error) {
+	return self.WaitSelectedStates(ctx, self.Selection(), previous)
+}
+
+// WaitSelectedStates is WaitSessionStates limited to selected sessions, nil selects
+// nothing and waits like a daemon where nothing changes
+func (self *MutagenMon) WaitSelectedStates(ctx context.Context, sessions *selection.Selection, previous uint64) (uint64, map[string]*synchronization.State, error) {
+	if sessions == nil {
+		if previous == 0 {
+			return 1, map[string]*synchronization.State{}, nil
+		}
+		<-ctx.Done()
+		return 0, nil, fmt.Errorf("get list of mutagen sessions: %w", status.FromContextError(ctx.Err()).Err())
+	}
+	connection := self.connection()
+	if connection == nil {
+		return 0, nil, ErrNoDaemon
+	}
+	synchronizationService := serviceSync.NewSynchronizationClient(connection)
 	request := &serviceSync.ListRequest{
-		Selection: &selection.Selection{All: true},
+		Selection:          sessions,
+		PreviousStateIndex: previous,
 	}
 	response, err := synchronizationService.List(ctx, request)
 	if err != nil {
-		return nil, fmt.Errorf("get list of mutagen sessions: %v", err)
+		return 0, nil, fmt.Errorf("get list of mutagen sessions: %w", err)
 	}
 	if response == nil {
-		return nil, fmt.Errorf("empty response")
+		return 0, nil, fmt.Errorf("empty response")
 	}
 	states := map[string]*synchronization.State{}
 	for _, state := range response.SessionStates {
@@ -114,17 +172,60 @@ func (self *MutagenMon) SessionStates(ctx context.Context) (map[string]*synchron
 		}
 		states[state.Session.Identifier] = state
 	}
-	return states, nil
+	return response.StateIndex, states, nil
 }
 
-func (self *MutagenMon) Scheduler() {
-	ctx := context.Background()
-	ticker := time.NewTicker(self.interval)
-	for range ticker.C {
-		states, err := self.SessionStates(ctx)
+// Scheduler long-polls the daemon and updates the menu only when session states change.
+// It returns when ctx is done.
+func (self *MutagenMon) Scheduler(ctx context.Context) {
+	for ctx.Err() == nil {
+		pollCtx, cancel, generation := self.pollContext(ctx)
+		if generation != self.stateRefresh {
+			// config or preset changed since the last poll, redraw everything
+			self.stateRefresh = generation
+			self.stateIndex = 0
+			for id, peer := range self.peers {
+				peer.state = nil
+				peer.label = ""
+				peer.tooltip = ""
+				self.peers[id] = peer
+			}
+		}
+		index, states, err := self.WaitSessionStates(pollCtx, self.stateIndex)
+		refreshed := errors.Is(pollCtx.Err(), context.Canceled)
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if refreshed {
+			continue
+		}
+		if status.Code(errors.Unwrap(err)) == codes.DeadlineExceeded {
+			// nothing changed, daemon is still there
+			continue
+		}
+		if daemonLost(err) {
+			if errors.Is(err, ErrNoDaemon) {
+				log.Printf("[INFO] waiting for mutagen daemon")
+			} else {
+				log.Printf("[WARN] lost mutagen daemon: %s", err)
+			}
+			self.Disconnected()
+			self.Reconnect(ctx)
+			continue
+		}
+		if status.Code(errors.Unwrap(err)) == codes.NotFound && self.dropMissing(ctx) {
+			// a selected session was terminated, list the rest
+			self.stateIndex = 0
+			continue
+		}
 		if err != nil {
 			log.Printf("[WARN] get states: %s", err)
+			self.ListFailed(err)
+			sleep(ctx, self.Config().Interval)
+			continue
 		}
+		self.stateIndex = index
 		err = self.CheckStates(ctx, states)
 		if err != nil {
 			log.Printf("[WARN] check states: %s", err)
@@ -132,6 +233,10 @@ func (self *MutagenMon) Scheduler() {
 	}
 }
 
+func sessionTitle(state *synchronization.State) string {
+	return fmt.Sprintf("%s:%s", state.Session.Beta.Host, state.Session.Beta.Path)
+}
+
 func hasConflicts(state *synchronization.State) bool {
 	if state == nil {
 		return false
@@ -139,145 +244,202 @@ func hasConflicts(state *synchronization.State) bool {
 	return len(state.Conflicts) > 0
 }
 
-func Icon(path string) []byte {
-	b, err := ioutil.ReadFile(path)
-	if err != nil {
-		return defaultIcon
-	}
-	return b
-}
-
-func (self *Peer) UpdateMenuItem(item *systray.MenuItem, state *synchronization.State) {
+func (self *Peer) UpdateMenuItem(item MenuItem, state *synchronization.State) {
 	if state == nil || item == nil {
 		return
 	}
 	log.Printf("[DEBUG] update menu item")
 
-	conflicts := map[string]*systray.MenuItem{}
-	var n int
-	for _, conflict := range state.GetConflicts() {
-		if n >= 60 {
-			item.AddSubMenuItem(fmt.Sprintf("... and %d more", len(state.Conflicts)-n), "")
-			break
-		}
-		n++
-		if conflict == nil {
-			continue
-		}
-		for _, change := range conflict.BetaChanges {
-			if change == nil {
-				continue
-			}
-			path := change.Path
+	self.updateConflicts(item, state)
+	self.updateProblems(state)
 
-			if len(path) > 70 {
-				path = path[:50] + " ... " + path[len(path)-15:]
-			}
-			if c, ok := self.conflicts[path]; ok {
-				conflicts[path] = c
-				delete(self.conflicts, path)
-			} else {
-				conflicts[path] = item.AddSubMenuItem(fmt.Sprintf("%s\n", path), "")
-			}
-		}
+	item.SetIcon(stateIcon(self.mon.Config().Classification.Of(state), hasConflicts(state)))
+}
+
+// sessionLabel renders the configured session label, without staging progress
+func sessionLabel(state *synchronization.State, config Config) string {
+	label, err := sessionInfo(state).Render(config.SessionLabelTemplate)
+	if err != nil {
+		log.Printf("[WARN] render session label: %s", err)
+		return sessionTitle(state)
+	}
+	return label
+}
+
+// UpdateLabel sets session label and tooltip from config templates, with staging progress if there is any
+func (self *Peer) UpdateLabel(state *synchronization.State) {
+	config := self.mon.Config()
+	info := sessionInfo(state)
+	label := sessionLabel(state, config)
+	if progress := self.progressLabel(state, time.Now()); progress != "" {
+		label += " — " + progress
+	}
+	if label != self.label {
+		self.menu.SetTitle(label)
+		self.label = label
 	}
-	for _, conflict := range self.conflicts {
-		conflict.Hide()
+	tooltip, err := info.Render(config.SessionTooltipTemplate)
+	if err != nil {
+		log.Printf("[WARN] render session tooltip: %s", err)
+		tooltip = ""
 	}
-	self.conflicts = conflicts
-
-	if is(state, disconnected) {
-		item.SetIcon(Icon("disconnected.png"))
-	} else if is(state, fatal) {
-		item.SetIcon(Icon("fatal.png"))
-	} else if is(state, syncing) {
-		SetIfNoConflict(state, item, "syncing.png")
-	} else if is(state, watching) {
-		SetIfNoConflict(state, item, "ok.png")
-	} else {
-		SetIfNoConflict(state, item, "unknown.png")
+	if tooltip != self.tooltip {
+		self.menu.SetTooltip(tooltip)
+		self.tooltip = tooltip
 	}
 }
 
-func SetIfNoConflict(state *synchronization.State, item *systray.MenuItem, name string) {
-	if hasConflicts(state) {
-		item.SetIcon(Icon("conflict.png"))
-		return
+// changed tells if the menu item of a session needs an update, conflicts and problems
+// are compared by content since they can be replaced by as many others
+func changed(previous *synchronization.State, current *synchronization.State, config Config) bool {
+	return previous == nil ||
+		previous.Status != current.Status ||
+		!slices.Equal(conflictKeys(previous, config), conflictKeys(current, config)) ||
+		previous.GetExcludedConflicts() != current.GetExcludedConflicts() ||
+		!slices.Equal(problemLines(previous), problemLines(current))
+}
+
+// shown drops sessions filtered out by config
+func shown(states map[string]*synchronization.State, config Config) map[string]*synchronization.State {
+	filtered := map[string]*synchronization.State{}
+	for id, state := range states {
+		if config.Shown(state.Session.Name, id, sessionTitle(state)) {
+			filtered[id] = state
+		}
 	}
-	item.SetIcon(Icon(name))
+	return filtered
 }
 
-func (self *MutagenMon) CheckStates(_ context.Context, states map[string]*synchronization.State) error {
-	var bad int
-	var conflict int
-	sync := "-"
+func (self *MutagenMon) CheckStates(ctx context.Context, states map[string]*synchronization.State) error {
+	if err := ctx.Err(); err != nil {
+		// shutting down, leave the menu alone
+		return err
+	}
+	config := self.Config()
+	states = shown(states, config)
 	for id, current := range states {
-		if is(current, syncing) {
-			sync = "•"
-		}
-		if is(current, disconnected) || is(current, fatal) {
-			bad++
-		}
-		if hasConflicts(current) {
-			conflict++
-		}
 		peer, ok := self.peers[id]
+		group := config.GroupOf(current)
+		if ok && peer.group != group {
+			// grouping changed, menu items can't be moved so the session gets a new one
+			peer.menu.Hide()
+			ok = false
+		}
 		if !ok {
-			item := systray.AddMenuItem(fmt.Sprintf("%s:%s", current.Session.Beta.Host, current.Session.Beta.Path), "")
+			item := self.sessionItem(group, sessionTitle(current))
 			peer = Peer{
+				mon:       self,
 				menu:      item,
 				state:     current,
-				conflicts: map[string]*systray.MenuItem{},
+				conflicts: map[string]MenuItem{},
+				group:     group,
 			}
+			peer.AddActions(self, current)
+			peer.AddProblems()
 			peer.UpdateMenuItem(item, current)
+			peer.UpdateLabel(current)
 			self.peers[id] = peer
+			self.notifier.Observe(id, sessionTitle(current), healthOf(current, config.Classification))
 			continue
 		}
 
-		if peer.state.Status != current.Status || len(peer.state.Conflicts) != len(current.Conflicts) {
+		if changed(peer.state, current, config) {
 			peer.UpdateMenuItem(peer.menu, current)
 			peer.state = current
-			self.peers[id] = peer
+			self.notifier.Observe(id, sessionTitle(current), healthOf(current, config.Classification))
 		}
+		peer.UpdateLabel(current)
+		self.peers[id] = peer
 	}
 	for id, peer := range self.peers {
 		_, ok := states[id]
 		if !ok {
 			peer.menu.Hide()
 			delete(self.peers, id)
+			self.notifier.Forget(id)
 		}
 	}
-	total := len(self.peers)
-	if sync != self.sync || bad != self.bad || total != self.total || conflict != self.conflict {
-		systray.SetTitle(fmt.Sprintf(`%d%s%d`, total-conflict-bad, sync, total-bad))
-	}
-	self.sync = sync
-	self.bad = bad
-	self.conflict = conflict
-	self.total = total
+	self.updateGroups(states, config)
+	self.titleLock.Lock()
+	self.summary = Summarize(states, config.Classification)
+	self.titleLock.Unlock()
+	self.UpdateTitle()
 	return nil
 }
 
-func (self *MutagenMon) Run() {
-	log.Printf("[INFO] Mutagenmon")
-	ep, err := os.Executable()
+// UpdateTitle sets the tray title from synchronization and forwarding counts
+func (self *MutagenMon) UpdateTitle() {
+	self.titleLock.Lock()
+	defer self.titleLock.Unlock()
+	summary := self.summary
+	summary.AddForwards(self.forwardStates)
+	config := self.Config()
+	title, err := summary.Render(config.TitleTemplate)
 	if err != nil {
-		log.Fatalln("os.Executable:", err)
+		log.Printf("[WARN] render title: %s", err)
+		return
 	}
-	err = os.Chdir(filepath.Join(filepath.Dir(ep), "..", "Resources"))
+	tooltip, err := summary.Render(config.TooltipTemplate)
 	if err != nil {
-		log.Fatalln("os.Chdir:", err)
+		log.Printf("[WARN] render tooltip: %s", err)
+		return
+	}
+	if title != self.title {
+		self.tray.SetTitle(title)
+		self.title = title
+	}
+	if tooltip != self.tooltip {
+		self.tray.SetTooltip(tooltip)
+		self.tooltip = tooltip
 	}
-	systray.Run(self.Init, nil)
+	self.updateTrayIcon(summary, config)
+}
+
+// Run shows the tray until Quit is clicked or SIGINT or SIGTERM is received
+func (self *MutagenMon) Run() {
+	log.Printf("[INFO] Mutagenmon")
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		select {
+		case received := <-signals:
+			log.Printf("[INFO] %s received, quitting", received)
+			self.tray.Quit()
+		case <-self.ctx.Done():
+		}
+	}()
+	systray.Run(self.Init, self.Stop)
+}
+
+// start runs a scheduler or watcher until Stop
+func (self *MutagenMon) start(worker func(ctx context.Context)) {
+	self.workers.Add(1)
+	go func() {
+		defer self.workers.Done()
+		worker(self.ctx)
+	}()
 }
 
 func (self *MutagenMon) Init() {
-	systray.SetIcon(Icon("icon.png"))
-	mQuit := systray.AddMenuItem("Quit Mutagen Monitor", "Author: https://www.andmed.org")
+	self.tray.SetIcon(Icon("icon.png"))
+	mQuit := self.tray.AddMenuItem("Quit Mutagen Monitor", "Author: https://www.andmed.org")
 	go func() {
-		<-mQuit.ClickedCh
-		systray.Quit()
+		<-mQuit.Clicked()
+		self.tray.Quit()
 	}()
-	systray.AddSeparator()
-	go self.Scheduler()
+	self.daemonLock.Lock()
+	self.startItem = self.tray.AddMenuItem(StartDaemonTitle, "Runs mutagen daemon start")
+	self.showStart()
+	self.daemonLock.Unlock()
+	go self.handleStart(self.startItem)
+	self.filterLock.Lock()
+	self.filterMenu = self.tray.AddMenuItem("Filter", "Sessions listed by the daemon")
+	self.filterLock.Unlock()
+	self.updateFilters()
+	self.tray.AddSeparator()
+	self.start(self.Scheduler)
+	self.start(self.ForwardScheduler)
+	self.start(self.WatchConfig)
+	self.start(self.WatchIcons)
 }
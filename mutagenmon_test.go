@@ -0,0 +1,285 @@
+package mutagenmon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/synchronization/core"
+	"github.com/mutagen-io/mutagen/pkg/url"
+)
+
+func newTestMonitor() (*MutagenMon, *FakeTray) {
+	tray := &FakeTray{}
+	mon := newMonitor(tray, nil, nil)
+	mon.notifier.send = func(string, string) error { return nil }
+	return mon, tray
+}
+
+func testState(id string, status synchronization.Status, conflicts ...string) *synchronization.State {
+	state := &synchronization.State{
+		Status: status,
+		Session: &synchronization.Session{
+			Identifier: id,
+			Alpha:      &url.URL{Protocol: url.Protocol_Local, Path: "/home/me/" + id},
+			Beta:       &url.URL{Protocol: url.Protocol_SSH, Host: "host", Path: "/srv/" + id},
+		},
+	}
+	for _, root := range conflicts {
+		state.Conflicts = append(state.Conflicts, &core.Conflict{
+			Root:         root,
+			AlphaChanges: []*core.Change{{Path: root}},
+			BetaChanges:  []*core.Change{{Path: root}},
+		})
+	}
+	return state
+}
+
+// testLabel is the default menu label of a testState session
+func testLabel(id string) string {
+	return "/home/me/" + id + " → ssh:host:/srv/" + id
+}
+
+func testStates(states ...*synchronization.State) map[string]*synchronization.State {
+	result := map[string]*synchronization.State{}
+	for _, state := range states {
+		result[state.Session.Identifier] = state
+	}
+	return result
+}
+
+func TestCheckStatesAddRemove(t *testing.T) {
+	mon, tray := newTestMonitor()
+	steps := []struct {
+		name  string
+		ids   []string
+		menu  []string
+		peers int
+	}{
+		{"empty", nil, []string{}, 0},
+		{"add two", []string{"a", "b"}, []string{testLabel("a"), testLabel("b")}, 2},
+		{"remove one", []string{"b"}, []string{testLabel("b")}, 1},
+		{"add back", []string{"a", "b", "c"}, []string{testLabel("a"), testLabel("b"), testLabel("c")}, 3},
+		{"remove all", nil, []string{}, 0},
+	}
+	for _, step := range steps {
+		states := testStates()
+		for _, id := range step.ids {
+			states[id] = testState(id, synchronization.Status_Watching)
+		}
+		err := mon.CheckStates(context.Background(), states)
+		if err != nil {
+			t.Fatal(err)
+		}
+		menu := tray.Visible()
+		sort.Strings(menu)
+		if !reflect.DeepEqual(menu, step.menu) {
+			t.Errorf("%s: menu %v, want %v", step.name, menu, step.menu)
+		}
+		if len(mon.peers) != step.peers {
+			t.Errorf("%s: %d peers, want %d", step.name, len(mon.peers), step.peers)
+		}
+	}
+}
+
+func TestStatusTransitions(t *testing.T) {
+	tests := []struct {
+		name      string
+		from      synchronization.Status
+		to        synchronization.Status
+		conflicts []string
+		icon      []byte
+	}{
+		{"connected", synchronization.Status_ConnectingBeta, synchronization.Status_Watching, nil, stateIcon(CategoryWatching, false)},
+		{"lost", synchronization.Status_Watching, synchronization.Status_Disconnected, nil, stateIcon(CategoryDisconnected, false)},
+		{"scanning", synchronization.Status_Watching, synchronization.Status_Scanning, nil, stateIcon(CategorySyncing, false)},
+		{"halted", synchronization.Status_Scanning, synchronization.Status_HaltedOnRootDeletion, nil, stateIcon(CategoryFatal, false)},
+		{"conflict", synchronization.Status_Scanning, synchronization.Status_Watching, []string{"x"}, stateIcon(CategoryWatching, true)},
+	}
+	for _, test := range tests {
+		mon, tray := newTestMonitor()
+		err := mon.CheckStates(context.Background(), testStates(testState("a", test.from)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = mon.CheckStates(context.Background(), testStates(testState("a", test.to, test.conflicts...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		item := tray.Find(testLabel("a"))
+		if item == nil {
+			t.Fatalf("%s: no menu item", test.name)
+		}
+		if !bytes.Equal(item.icon, test.icon) {
+			t.Errorf("%s: wrong icon", test.name)
+		}
+	}
+}
+
+func TestConflictDiffing(t *testing.T) {
+	mon, tray := newTestMonitor()
+	steps := []struct {
+		roots []string
+		shown []string
+		added int
+	}{
+		{[]string{"a", "b"}, []string{"a", "b"}, 2},
+		{[]string{"b", "c", "d"}, []string{"b", "c", "d"}, 2},
+		{[]string{"d"}, []string{"d"}, 0},
+		{nil, []string{}, 0},
+		{[]string{"a"}, []string{"a"}, 1},
+	}
+	total := 0
+	for n, step := range steps {
+		err := mon.CheckStates(context.Background(), testStates(testState("s", synchronization.Status_Watching, step.roots...)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		item := tray.Find(testLabel("s"))
+		shown := []string{}
+		conflicts := 0
+		for _, child := range item.children {
+			if !strings.Contains(child.title, "(alpha") {
+				continue
+			}
+			conflicts++
+			if !child.hidden {
+				shown = append(shown, strings.Fields(child.title)[0])
+			}
+		}
+		sort.Strings(shown)
+		if !reflect.DeepEqual(shown, step.shown) {
+			t.Errorf("step %d: conflicts %v, want %v", n, shown, step.shown)
+		}
+		if conflicts-total != step.added {
+			t.Errorf("step %d: %d items added, want %d", n, conflicts-total, step.added)
+		}
+		total = conflicts
+	}
+}
+
+func TestTrayTitle(t *testing.T) {
+	tests := []struct {
+		name    string
+		states  map[string]*synchronization.State
+		title   string
+		tooltip string
+	}{
+		{"no sessions", testStates(), "0-0", "0 sessions: 0 healthy, 0 with conflicts, 0 disconnected or halted"},
+		{"healthy", testStates(
+			testState("a", synchronization.Status_Watching),
+			testState("b", synchronization.Status_Watching),
+		), "2-2", "2 sessions: 2 healthy, 0 with conflicts, 0 disconnected or halted"},
+		{"mixed", testStates(
+			testState("a", synchronization.Status_Watching),
+			testState("b", synchronization.Status_Scanning),
+			testState("c", synchronization.Status_Watching, "x"),
+			testState("d", synchronization.Status_Disconnected),
+		), "2•3", "4 sessions: 2 healthy, 1 with conflicts, 1 disconnected or halted"},
+	}
+	for _, test := range tests {
+		mon, tray := newTestMonitor()
+		err := mon.CheckStates(context.Background(), test.states)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tray.title != test.title {
+			t.Errorf("%s: title %q, want %q", test.name, tray.title, test.title)
+		}
+		if tray.tooltip != test.tooltip {
+			t.Errorf("%s: tooltip %q, want %q", test.name, tray.tooltip, test.tooltip)
+		}
+		if len(tray.icon) == 0 {
+			t.Errorf("%s: no tray icon", test.name)
+		}
+	}
+}
+
+func TestExcludedConflicts(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxConflicts int
+		listed       int
+		excluded     uint64
+		more         string
+	}{
+		{"excluded by daemon", 60, 10, 5, "... and 5 more"},
+		{"cut by config", 4, 10, 5, "... and 11 more"},
+		{"all listed", 60, 10, 0, ""},
+	}
+	for _, test := range tests {
+		mon, tray := newTestMonitor()
+		mon.config.MaxConflicts = test.maxConflicts
+		roots := []string{}
+		for n := 0; n < test.listed; n++ {
+			roots = append(roots, fmt.Sprintf("c%d", n))
+		}
+		state := testState("s", synchronization.Status_Watching, roots...)
+		state.ExcludedConflicts = test.excluded
+		err := mon.CheckStates(context.Background(), testStates(state))
+		if err != nil {
+			t.Fatal(err)
+		}
+		more := ""
+		conflicts := 0
+		for _, title := range tray.Find(testLabel("s")).Visible() {
+			if strings.HasPrefix(title, "... and") {
+				more = title
+			}
+			if strings.Contains(title, "(alpha") {
+				conflicts++
+			}
+		}
+		if more != test.more {
+			t.Errorf("%s: got %q, want %q", test.name, more, test.more)
+		}
+		if want := min(test.listed, test.maxConflicts); conflicts != want {
+			t.Errorf("%s: %d conflicts shown, want %d", test.name, conflicts, want)
+		}
+	}
+}
+
+func TestSameCountUpdates(t *testing.T) {
+	mon, tray := newTestMonitor()
+	steps := []struct {
+		conflict string
+		problem  string
+	}{
+		{"a", "x"},
+		{"b", "x"},
+		{"b", "y"},
+	}
+	for n, step := range steps {
+		state := testState("s", synchronization.Status_Watching, step.conflict)
+		state.AlphaState = &synchronization.EndpointState{
+			ScanProblems: []*core.Problem{{Path: step.problem, Error: "permission denied"}},
+		}
+		err := mon.CheckStates(context.Background(), testStates(state))
+		if err != nil {
+			t.Fatal(err)
+		}
+		item := tray.Find(testLabel("s"))
+		conflicts := []string{}
+		for _, title := range item.Visible() {
+			if strings.Contains(title, "(alpha") {
+				conflicts = append(conflicts, strings.Fields(title)[0])
+			}
+		}
+		if want := []string{step.conflict}; !reflect.DeepEqual(conflicts, want) {
+			t.Errorf("step %d: conflicts %v, want %v", n, conflicts, want)
+		}
+		problems := item.Find("Problems (1)")
+		if problems == nil {
+			t.Fatalf("step %d: no problems submenu", n)
+		}
+		want := []string{"alpha scan: " + step.problem + ": permission denied"}
+		if got := problems.Visible(); !reflect.DeepEqual(got, want) {
+			t.Errorf("step %d: problems %v, want %v", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package mutagenmon
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+// NotifyDelay is how long a session has to stay in a new state before we notify,
+// so flapping between connecting and disconnected does not spam
+const NotifyDelay = 10 * time.Second
+
+const (
+	healthOk           = "ok"
+	healthConflict     = "conflict"
+	healthDisconnected = "disconnected"
+	healthFatal        = "fatal"
+)
+
+type health struct {
+	category  string
+	conflicts int
+	status    synchronization.Status
+}
+
+func healthOf(state *synchronization.State, classes Classification) health {
+	current := health{conflicts: len(state.GetConflicts()), status: state.GetStatus()}
+	category := classes.Of(state)
+	if category == CategoryFatal {
+		current.category = healthFatal
+	} else if category == CategoryDisconnected {
+		current.category = healthDisconnected
+	} else if hasConflicts(state) {
+		current.category = healthConflict
+	} else if category == CategoryWatching || category == CategorySyncing {
+		current.category = healthOk
+	}
+	return current
+}
+
+// Notifier sends desktop notifications when session health changes
+type Notifier struct {
+	lock    sync.Mutex
+	delay   time.Duration
+	sent    map[string]health
+	pending map[string]func() bool // stops a scheduled notification
+	send    func(title, message string) error
+	after   func(delay time.Duration, f func()) (stop func() bool)
+}
+
+func NewNotifier() *Notifier {
+	return &Notifier{
+		delay:   NotifyDelay,
+		sent:    map[string]health{},
+		pending: map[string]func() bool{},
+		send:    notify,
+		after: func(delay time.Duration, f func()) func() bool {
+			return time.AfterFunc(delay, f).Stop
+		},
+	}
+}
+
+// Observe schedules a notification if session health differs from the last notified one
+func (self *Notifier) Observe(id string, name string, current health) {
+	if current.category == "" {
+		// transient or unknown status, wait for something definite
+		return
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if stop, ok := self.pending[id]; ok {
+		stop()
+		delete(self.pending, id)
+	}
+	last, ok := self.sent[id]
+	if !ok {
+		// first time we see the session, nothing to compare with
+		self.sent[id] = current
+		return
+	}
+	if last.category == current.category && last.conflicts == current.conflicts {
+		return
+	}
+	self.pending[id] = self.after(self.delay, func() {
+		self.fire(id, name, current)
+	})
+}
+
+// Forget drops a removed session
+func (self *Notifier) Forget(id string) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if stop, ok := self.pending[id]; ok {
+		stop()
+		delete(self.pending, id)
+	}
+	delete(self.sent, id)
+}
+
+// Stop cancels notifications that are not sent yet
+func (self *Notifier) Stop() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for id, stop := range self.pending {
+		stop()
+		delete(self.pending, id)
+	}
+}
+
+func (self *Notifier) fire(id string, name string, current health) {
+	self.lock.Lock()
+	last := self.sent[id]
+	self.sent[id] = current
+	delete(self.pending, id)
+	self.lock.Unlock()
+
+	message := describeChange(last, current)
+	if message == "" {
+		return
+	}
+	err := self.send(name, message)
+	if err != nil {
+		log.Printf("[WARN] send notification: %s", err)
+	}
+}
+
+func describeChange(last health, current health) string {
+	switch current.category {
+	case healthFatal:
+		if last.category != healthFatal {
+			return current.status.Description()
+		}
+	case healthDisconnected:
+		if last.category != healthDisconnected {
+			return "Disconnected"
+		}
+	case healthConflict:
+		if current.conflicts > last.conflicts {
+			return fmt.Sprintf("%d new conflicts, %d total", current.conflicts-last.conflicts, current.conflicts)
+		}
+	case healthOk:
+		if last.category != healthOk {
+			return "Recovered"
+		}
+	}
+	return ""
+}
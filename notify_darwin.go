@@ -0,0 +1,16 @@
+package mutagenmon
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// notify sends a notification via Notification Center
+func notify(title string, message string) error {
+	script := fmt.Sprintf("display notification %q with title \"Mutagen Monitor\" subtitle %q", message, title)
+	output, err := exec.Command("osascript", "-e", script).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("osascript: %v: %s", err, output)
+	}
+	return nil
+}
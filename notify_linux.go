@@ -0,0 +1,22 @@
+package mutagenmon
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// notify sends a notification via org.freedesktop.Notifications
+func notify(title string, message string) error {
+	connection, err := dbus.SessionBus()
+	if err != nil {
+		return fmt.Errorf("connect to session bus: %v", err)
+	}
+	notifications := connection.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	call := notifications.Call("org.freedesktop.Notifications.Notify", 0,
+		"Mutagen Monitor", uint32(0), "", title, message, []string{}, map[string]dbus.Variant{}, int32(-1))
+	if call.Err != nil {
+		return fmt.Errorf("notify: %v", call.Err)
+	}
+	return nil
+}
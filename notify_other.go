@@ -0,0 +1,10 @@
+//go:build !linux && !darwin
+
+package mutagenmon
+
+import "log"
+
+func notify(title string, message string) error {
+	log.Printf("[INFO] %s: %s", title, message)
+	return nil
+}
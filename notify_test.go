@@ -0,0 +1,98 @@
+package mutagenmon
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+// fakeTimers replaces time.AfterFunc, scheduled functions run on elapse
+type fakeTimers struct {
+	scheduled map[int]func()
+	next      int
+}
+
+func (self *fakeTimers) after(_ time.Duration, f func()) func() bool {
+	n := self.next
+	self.next++
+	self.scheduled[n] = f
+	return func() bool {
+		_, ok := self.scheduled[n]
+		delete(self.scheduled, n)
+		return ok
+	}
+}
+
+func (self *fakeTimers) elapse() {
+	keys := []int{}
+	for n := range self.scheduled {
+		keys = append(keys, n)
+	}
+	sort.Ints(keys)
+	for _, n := range keys {
+		f := self.scheduled[n]
+		delete(self.scheduled, n)
+		f()
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	watching := testState("a", synchronization.Status_Watching)
+	connecting := testState("a", synchronization.Status_ConnectingBeta)
+	conflicted := testState("a", synchronization.Status_Watching, "x")
+	halted := testState("a", synchronization.Status_HaltedOnRootDeletion)
+	elapse := (*synchronization.State)(nil)
+
+	tests := []struct {
+		name  string
+		steps []*synchronization.State // nil lets the delay pass
+		sent  []string
+	}{
+		{"flapping within delay", []*synchronization.State{watching, connecting, watching, connecting, watching, elapse}, nil},
+		{"disconnect", []*synchronization.State{watching, connecting, elapse}, []string{"Disconnected"}},
+		{"new conflict", []*synchronization.State{watching, conflicted, elapse, conflicted, elapse}, []string{"1 new conflicts, 1 total"}},
+		{"recovered", []*synchronization.State{watching, halted, elapse, watching, elapse},
+			[]string{synchronization.Status_HaltedOnRootDeletion.Description(), "Recovered"}},
+		{"first sighting", []*synchronization.State{halted, elapse}, nil},
+	}
+	classes := DefaultClassification()
+	for _, test := range tests {
+		timers := &fakeTimers{scheduled: map[int]func(){}}
+		var sent []string
+		notifier := NewNotifier()
+		notifier.after = timers.after
+		notifier.send = func(title string, message string) error {
+			if title != "host:/srv/a" {
+				t.Errorf("%s: title %q", test.name, title)
+			}
+			sent = append(sent, message)
+			return nil
+		}
+		for _, state := range test.steps {
+			if state == elapse {
+				timers.elapse()
+				continue
+			}
+			notifier.Observe("a", sessionTitle(state), healthOf(state, classes))
+		}
+		if !reflect.DeepEqual(sent, test.sent) {
+			t.Errorf("%s: sent %q, want %q", test.name, sent, test.sent)
+		}
+	}
+
+	// a removed session gets no pending notification
+	timers := &fakeTimers{scheduled: map[int]func(){}}
+	notifier := NewNotifier()
+	notifier.after = timers.after
+	notifier.send = func(string, string) error {
+		t.Error("forgotten session notified")
+		return nil
+	}
+	notifier.Observe("a", "a", healthOf(watching, classes))
+	notifier.Observe("a", "a", healthOf(connecting, classes))
+	notifier.Forget("a")
+	timers.elapse()
+}
@@ -0,0 +1,78 @@
+package mutagenmon
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+// problemLines lists last error and endpoint problems as menu lines
+func problemLines(state *synchronization.State) []string {
+	var lines []string
+	if state.GetLastError() != "" {
+		lines = append(lines, "Last error: "+state.LastError)
+	}
+	for _, endpoint := range []struct {
+		name  string
+		state *synchronization.EndpointState
+	}{{"alpha", state.GetAlphaState()}, {"beta", state.GetBetaState()}} {
+		for _, problem := range endpoint.state.GetScanProblems() {
+			lines = append(lines, fmt.Sprintf("%s scan: %s: %s", endpoint.name, problem.GetPath(), problem.GetError()))
+		}
+		if excluded := endpoint.state.GetExcludedScanProblems(); excluded > 0 {
+			lines = append(lines, fmt.Sprintf("%s scan: %d more problems excluded", endpoint.name, excluded))
+		}
+		for _, problem := range endpoint.state.GetTransitionProblems() {
+			lines = append(lines, fmt.Sprintf("%s transition: %s: %s", endpoint.name, problem.GetPath(), problem.GetError()))
+		}
+		if excluded := endpoint.state.GetExcludedTransitionProblems(); excluded > 0 {
+			lines = append(lines, fmt.Sprintf("%s transition: %d more problems excluded", endpoint.name, excluded))
+		}
+	}
+	return lines
+}
+
+// AddProblems adds the hidden problems submenu, it is shown once there is something in it
+func (self *Peer) AddProblems() {
+	self.problems = self.menu.AddSubMenuItem("Problems", "Click an entry to copy it")
+	self.problems.Hide()
+	self.problemItems = map[string]MenuItem{}
+}
+
+func (self *Peer) updateProblems(state *synchronization.State) {
+	if self.problems == nil {
+		return
+	}
+	lines := problemLines(state)
+	items := map[string]MenuItem{}
+	for _, line := range lines {
+		if item, ok := self.problemItems[line]; ok {
+			items[line] = item
+			delete(self.problemItems, line)
+			continue
+		}
+		item := self.problems.AddSubMenuItem(self.mon.Config().Shorten(line), line)
+		go handleCopy(item, line)
+		items[line] = item
+	}
+	for _, item := range self.problemItems {
+		item.Hide()
+	}
+	self.problemItems = items
+	if len(lines) == 0 {
+		self.problems.Hide()
+		return
+	}
+	self.problems.SetTitle(fmt.Sprintf("Problems (%d)", len(lines)))
+	self.problems.Show()
+}
+
+func handleCopy(item MenuItem, text string) {
+	for range item.Clicked() {
+		err := copyToClipboard(text)
+		if err != nil {
+			log.Printf("[WARN] copy to clipboard: %s", err)
+		}
+	}
+}
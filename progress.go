@@ -0,0 +1,83 @@
+package mutagenmon
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/synchronization/rsync"
+)
+
+// staging remembers where the current staging operation started to estimate its rate
+type staging struct {
+	started  time.Time
+	files    uint64
+	bytes    uint64
+	expected uint64
+}
+
+func stagingProgress(state *synchronization.State) *rsync.ReceiverState {
+	if state.GetStatus() == synchronization.Status_StagingAlpha {
+		return state.GetAlphaState().GetStagingProgress()
+	}
+	if state.GetStatus() == synchronization.Status_StagingBeta {
+		return state.GetBetaState().GetStagingProgress()
+	}
+	return nil
+}
+
+func thousands(n uint64) string {
+	digits := strconv.FormatUint(n, 10)
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
+}
+
+func humanBytes(n float64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	unit := 0
+	for n >= 1000 && unit < len(units)-1 {
+		n /= 1000
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%.0f %s", n, units[unit])
+	}
+	return fmt.Sprintf("%.1f %s", n, units[unit])
+}
+
+// progressLabel describes staging progress, rate and ETA, it is empty when nothing is staged
+func (self *Peer) progressLabel(state *synchronization.State, now time.Time) string {
+	progress := stagingProgress(state)
+	if progress == nil || progress.ExpectedFiles == 0 {
+		self.staging = nil
+		return ""
+	}
+	// a new staging operation starts over, rates are measured from its start
+	if self.staging == nil || self.staging.expected != progress.ExpectedFiles ||
+		progress.ReceivedFiles < self.staging.files || progress.TotalReceivedSize < self.staging.bytes {
+		self.staging = &staging{
+			started:  now,
+			files:    progress.ReceivedFiles,
+			bytes:    progress.TotalReceivedSize,
+			expected: progress.ExpectedFiles,
+		}
+	}
+	label := fmt.Sprintf("staging %s/%s files, %d%%",
+		thousands(progress.ReceivedFiles), thousands(progress.ExpectedFiles), progress.ReceivedFiles*100/progress.ExpectedFiles)
+
+	elapsed := now.Sub(self.staging.started).Seconds()
+	if elapsed < 1 {
+		return label
+	}
+	bytesRate := float64(progress.TotalReceivedSize-self.staging.bytes) / elapsed
+	filesRate := float64(progress.ReceivedFiles-self.staging.files) / elapsed
+	label += fmt.Sprintf(", %s/s", humanBytes(bytesRate))
+	if filesRate > 0 && progress.ReceivedFiles < progress.ExpectedFiles {
+		eta := time.Duration(float64(progress.ExpectedFiles-progress.ReceivedFiles) / filesRate * float64(time.Second))
+		label += fmt.Sprintf(", ETA %s", eta.Round(time.Second))
+	}
+	return label
+}
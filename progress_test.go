@@ -0,0 +1,46 @@
+package mutagenmon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/synchronization/rsync"
+)
+
+func TestProgressLabel(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	staging := func(files, expected, bytes uint64) *synchronization.State {
+		return &synchronization.State{
+			Status: synchronization.Status_StagingBeta,
+			BetaState: &synchronization.EndpointState{StagingProgress: &rsync.ReceiverState{
+				ReceivedFiles:     files,
+				ExpectedFiles:     expected,
+				TotalReceivedSize: bytes,
+			}},
+		}
+	}
+	steps := []struct {
+		name  string
+		state *synchronization.State
+		after time.Duration
+		want  string
+	}{
+		{"started", staging(0, 100, 0), 0, "staging 0/100 files, 0%"},
+		{"under a second", staging(5, 100, 500), 500 * time.Millisecond, "staging 5/100 files, 5%"},
+		{"rate and ETA", staging(10, 100, 2_000_000), 2 * time.Second, "staging 10/100 files, 10%, 800.0 KB/s, ETA 23s"},
+		{"thousands", staging(1500, 100_000, 2_000_000), 0, "staging 1,500/100,000 files, 1%"},
+		{"bytes went back", staging(1500, 100_000, 1_000), 0, "staging 1,500/100,000 files, 1%"},
+		{"after restart", staging(1600, 100_000, 3_000), 2 * time.Second, "staging 1,600/100,000 files, 1%, 1.0 KB/s, ETA 32m48s"},
+		{"done", staging(100_000, 100_000, 5_000), 0, "staging 100,000/100,000 files, 100%, 2.0 KB/s"},
+		{"watching", &synchronization.State{Status: synchronization.Status_Watching}, 0, ""},
+	}
+	peer := Peer{}
+	now := start
+	for _, step := range steps {
+		now = now.Add(step.after)
+		if got := peer.progressLabel(step.state, now); got != step.want {
+			t.Errorf("%s: got %q, want %q", step.name, got, step.want)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package mutagenmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mutagen-io/mutagen/pkg/docker"
+	"github.com/mutagen-io/mutagen/pkg/environment"
+	"github.com/mutagen-io/mutagen/pkg/selection"
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/url"
+)
+
+const (
+	KeepAlpha = "Keep alpha"
+	KeepBeta  = "Keep beta"
+)
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// remotePath quotes path for a remote shell keeping home directory expansion
+func remotePath(p string) string {
+	if p == "~" {
+		return p
+	}
+	if strings.HasPrefix(p, "~/") {
+		return "~/" + shellQuote(p[2:])
+	}
+	return shellQuote(p)
+}
+
+// dockerEnvironment replaces docker variables of base with the ones captured in the endpoint URL,
+// variables that were not set when the session was created are dropped
+func dockerEnvironment(base []string, captured map[string]string) []string {
+	result := environment.ToMap(base)
+	for _, variable := range url.DockerEnvironmentVariables {
+		if value, ok := captured[variable]; ok {
+			result[variable] = value
+		} else {
+			delete(result, variable)
+		}
+	}
+	return environment.FromMap(result)
+}
+
+// removeCommand builds the command deleting target on a remote endpoint, hook replaces ssh/docker if set
+func removeCommand(ctx context.Context, endpoint *url.URL, target string, hook string) (*exec.Cmd, error) {
+	if hook != "" {
+		command := exec.CommandContext(ctx, "sh", "-c", hook)
+		protocol, _ := endpoint.Protocol.MarshalText()
+		command.Env = append(os.Environ(),
+			"MUTAGENMON_PROTOCOL="+string(protocol),
+			"MUTAGENMON_USER="+endpoint.User,
+			"MUTAGENMON_HOST="+endpoint.Host,
+			"MUTAGENMON_PORT="+strconv.Itoa(int(endpoint.Port)),
+			"MUTAGENMON_PATH="+target,
+		)
+		return command, nil
+	}
+	switch endpoint.Protocol {
+	case url.Protocol_SSH:
+		args := []string{}
+		if endpoint.Port != 0 {
+			args = append(args, "-p", strconv.Itoa(int(endpoint.Port)))
+		}
+		host := endpoint.Host
+		if endpoint.User != "" {
+			host = endpoint.User + "@" + host
+		}
+		args = append(args, host, "rm -rf -- "+remotePath(target))
+		return exec.CommandContext(ctx, "ssh", args...), nil
+	case url.Protocol_Docker:
+		// talk to the same docker daemon as mutagen's docker transport does
+		flags, err := docker.LoadDaemonConnectionFlagsFromURLParameters(endpoint.Parameters)
+		if err != nil {
+			return nil, fmt.Errorf("docker connection flags: %v", err)
+		}
+		args := append(flags.ToFlags(), "exec")
+		if endpoint.User != "" {
+			args = append(args, "--user", endpoint.User)
+		}
+		args = append(args, endpoint.Host, "sh", "-c", "rm -rf -- "+remotePath(target))
+		command := exec.CommandContext(ctx, "docker", args...)
+		command.Env = dockerEnvironment(os.Environ(), endpoint.Environment)
+		return command, nil
+	}
+	return nil, fmt.Errorf("unsupported endpoint protocol %s", endpoint.Protocol)
+}
+
+// removeEntry deletes a path relative to the endpoint synchronization root
+func removeEntry(ctx context.Context, endpoint *url.URL, relative string, hook string) error {
+	if relative == "" {
+		return fmt.Errorf("refusing to remove synchronization root")
+	}
+	if endpoint.Protocol == url.Protocol_Local {
+		return os.RemoveAll(filepath.Join(endpoint.Path, filepath.FromSlash(relative)))
+	}
+	command, err := removeCommand(ctx, endpoint, path.Join(endpoint.Path, relative), hook)
+	if err != nil {
+		return err
+	}
+	output, err := command.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %v: %s", command, err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// loser returns the side of the session that a resolution deletes from
+func loser(session *synchronization.Session, keep string) (string, *url.URL, error) {
+	switch keep {
+	case KeepAlpha:
+		return "beta", session.Beta, nil
+	case KeepBeta:
+		return "alpha", session.Alpha, nil
+	}
+	return "", nil, fmt.Errorf("unknown resolution %q", keep)
+}
+
+// Resolve removes the losing side of a conflict and flushes the session
+func (self *MutagenMon) Resolve(ctx context.Context, session *synchronization.Session, root string, keep string) error {
+	_, endpoint, err := loser(session, keep)
+	if err != nil {
+		return err
+	}
+	err = removeEntry(ctx, endpoint, root, self.Config().RemoveHook)
+	if err != nil {
+		return fmt.Errorf("remove %s: %v", root, err)
+	}
+	return self.Act(ctx, ActionFlush, &selection.Selection{Specifications: []string{session.Identifier}})
+}
+
+// AddResolutions adds keep alpha/beta submenus to a conflict item. Nothing is deleted
+// until the confirmation inside the submenu, which names the path, is clicked.
+func (self *MutagenMon) AddResolutions(item MenuItem, session *synchronization.Session, root string) {
+	config := self.Config()
+	for _, keep := range []string{KeepAlpha, KeepBeta} {
+		side, endpoint, _ := loser(session, keep)
+		parent := item.AddSubMenuItem(keep, "")
+		confirm := parent.AddSubMenuItem(
+			fmt.Sprintf("Confirm: delete %s on %s", config.Shorten(root), side),
+			path.Join(endpoint.GetPath(), root),
+		)
+		go self.handleResolve(parent, confirm, session, root, keep)
+	}
+}
+
+func (self *MutagenMon) handleResolve(parent MenuItem, confirm MenuItem, session *synchronization.Session, root string, keep string) {
+	for range confirm.Clicked() {
+		confirm.Disable()
+		parent.SetTitle(keep + " ...")
+		ctx, cancel := context.WithTimeout(self.ctx, ActionTimeout)
+		err := self.Resolve(ctx, session, root, keep)
+		cancel()
+		if err != nil {
+			log.Printf("[WARN] %s for %s in session %s: %s", keep, root, session.Identifier, err)
+			parent.SetTitle(keep + " ✗")
+			parent.SetTooltip(err.Error())
+		} else {
+			parent.SetTitle(keep + " ✓")
+			parent.SetTooltip("")
+		}
+		confirm.Enable()
+	}
+}
@@ -0,0 +1,170 @@
+package mutagenmon
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+	"github.com/mutagen-io/mutagen/pkg/url"
+)
+
+func TestResolutionNeedsConfirmation(t *testing.T) {
+	mon, tray := newTestMonitor()
+	err := mon.CheckStates(context.Background(), testStates(testState("s", synchronization.Status_Watching, "src/app")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conflict *FakeItem
+	for _, child := range tray.Find(testLabel("s")).children {
+		if strings.HasPrefix(child.title, "src/app") {
+			conflict = child
+		}
+	}
+	if conflict == nil {
+		t.Fatal("no conflict item")
+	}
+	want := map[string]string{
+		KeepAlpha: "Confirm: delete src/app on beta",
+		KeepBeta:  "Confirm: delete src/app on alpha",
+	}
+	for _, keep := range conflict.children[:2] {
+		if !reflect.DeepEqual(keep.Visible(), []string{want[keep.title]}) {
+			t.Errorf("%s: %v", keep.title, keep.Visible())
+		}
+	}
+}
+
+func TestRemotePath(t *testing.T) {
+	tests := map[string]string{
+		"/srv/app":     `'/srv/app'`,
+		"~":            `~`,
+		"~/src/it's":   `~/'src/it'\''s'`,
+		"relative dir": `'relative dir'`,
+	}
+	for path, want := range tests {
+		if got := remotePath(path); got != want {
+			t.Errorf("%s: got %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestRemoveCommand(t *testing.T) {
+	t.Setenv("DOCKER_HOST", "unix:///var/run/other.sock")
+	tests := []struct {
+		name     string
+		endpoint *url.URL
+		hook     string
+		args     []string
+		env      []string // must be in command environment
+		unset    string   // must not be in command environment
+	}{
+		{
+			name:     "ssh",
+			endpoint: &url.URL{Protocol: url.Protocol_SSH, Host: "build"},
+			args:     []string{"ssh", "build", `rm -rf -- '/srv/app/x'`},
+		},
+		{
+			name:     "ssh with user and port",
+			endpoint: &url.URL{Protocol: url.Protocol_SSH, User: "me", Host: "build", Port: 2222},
+			args:     []string{"ssh", "-p", "2222", "me@build", `rm -rf -- '/srv/app/x'`},
+		},
+		{
+			name:     "docker",
+			endpoint: &url.URL{Protocol: url.Protocol_Docker, User: "node", Host: "web"},
+			args:     []string{"docker", "exec", "--user", "node", "web", "sh", "-c", `rm -rf -- '/srv/app/x'`},
+			unset:    "DOCKER_HOST=",
+		},
+		{
+			name: "docker with captured daemon",
+			endpoint: &url.URL{
+				Protocol:    url.Protocol_Docker,
+				Host:        "web",
+				Environment: map[string]string{"DOCKER_HOST": "tcp://docker:2375"},
+				Parameters:  map[string]string{"context": "remote"},
+			},
+			args: []string{"docker", "--context", "remote", "exec", "web", "sh", "-c", `rm -rf -- '/srv/app/x'`},
+			env:  []string{"DOCKER_HOST=tcp://docker:2375"},
+		},
+		{
+			name:     "hook",
+			endpoint: &url.URL{Protocol: url.Protocol_SSH, User: "me", Host: "build", Port: 22},
+			hook:     "my-remove",
+			args:     []string{"sh", "-c", "my-remove"},
+			env: []string{
+				"MUTAGENMON_PROTOCOL=ssh", "MUTAGENMON_USER=me", "MUTAGENMON_HOST=build",
+				"MUTAGENMON_PORT=22", "MUTAGENMON_PATH=/srv/app/x",
+			},
+		},
+	}
+	for _, test := range tests {
+		command, err := removeCommand(context.Background(), test.endpoint, "/srv/app/x", test.hook)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		args := append([]string{filepath.Base(command.Args[0])}, command.Args[1:]...)
+		if !reflect.DeepEqual(args, test.args) {
+			t.Errorf("%s: args %q, want %q", test.name, args, test.args)
+		}
+		for _, variable := range test.env {
+			if !contains(command.Env, variable) {
+				t.Errorf("%s: %s is not in environment", test.name, variable)
+			}
+		}
+		for _, variable := range command.Env {
+			if test.unset != "" && strings.HasPrefix(variable, test.unset) {
+				t.Errorf("%s: %s should not be in environment", test.name, variable)
+			}
+		}
+	}
+	_, err := removeCommand(context.Background(), &url.URL{Protocol: url.Protocol_Local}, "/x", "")
+	if err == nil {
+		t.Error("local endpoints are not removed by a command")
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRemoveEntryLocal(t *testing.T) {
+	root := t.TempDir()
+	for _, file := range []string{"keep/a", "conflict/dir/b", "conflict/c"} {
+		err := os.MkdirAll(filepath.Join(root, filepath.Dir(file)), 0o700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(filepath.Join(root, file), []byte("x"), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	endpoint := &url.URL{Protocol: url.Protocol_Local, Path: root}
+
+	err := removeEntry(context.Background(), endpoint, "", "")
+	if err == nil {
+		t.Error("root must not be removed")
+	}
+	if _, err := os.Stat(filepath.Join(root, "keep/a")); err != nil {
+		t.Fatalf("root was touched: %v", err)
+	}
+
+	err = removeEntry(context.Background(), endpoint, "conflict", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "conflict")); !os.IsNotExist(err) {
+		t.Errorf("conflict is still there: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "keep/a")); err != nil {
+		t.Errorf("sibling was removed: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package mutagenmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+const StartDaemonTitle = "Start Mutagen daemon"
+
+// mutagenPaths are checked when mutagen is not in PATH, apps started from the Dock don't get the shell PATH
+var mutagenPaths = []string{"/opt/homebrew/bin/mutagen", "/usr/local/bin/mutagen"}
+
+// mutagenBinary finds the mutagen command line tool
+func mutagenBinary() (string, error) {
+	path, err := exec.LookPath("mutagen")
+	if err == nil {
+		return path, nil
+	}
+	for _, candidate := range mutagenPaths {
+		if info, statErr := os.Stat(candidate); statErr == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("mutagen is not found: %v", err)
+}
+
+// StartDaemon runs "mutagen daemon start", schedulers connect to it on their next attempt
+func (self *MutagenMon) StartDaemon(ctx context.Context) error {
+	binary, err := mutagenBinary()
+	if err != nil {
+		return err
+	}
+	output, err := exec.CommandContext(ctx, binary, "daemon", "start").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s daemon start: %v: %s", binary, err, strings.TrimSpace(string(output)))
+	}
+	log.Printf("[INFO] started mutagen daemon")
+	return nil
+}
+
+func (self *MutagenMon) handleStart(item MenuItem) {
+	for range item.Clicked() {
+		item.Disable()
+		item.SetTitle(StartDaemonTitle + " ...")
+		ctx, cancel := context.WithTimeout(self.ctx, ActionTimeout)
+		err := self.StartDaemon(ctx)
+		cancel()
+		if err != nil {
+			log.Printf("[WARN] start daemon: %s", err)
+			item.SetTitle(StartDaemonTitle + " ✗")
+			item.SetTooltip(err.Error())
+		} else {
+			item.SetTitle(StartDaemonTitle)
+			item.SetTooltip("")
+		}
+		item.Enable()
+	}
+}
+
+// showStart shows the start item only while there is no daemon, daemonLock must be held
+func (self *MutagenMon) showStart() {
+	if self.startItem == nil {
+		return
+	}
+	if self.daemon == nil {
+		self.startItem.Show()
+		return
+	}
+	self.startItem.Hide()
+}
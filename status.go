@@ -0,0 +1,130 @@
+package mutagenmon
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/mutagen-io/mutagen/pkg/forwarding"
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+// Summary holds the counts shown in the tray title. Healthy, Conflicted and Bad add up to Total,
+// Conflicts counts sessions with conflicts whether they are bad or not.
+type Summary struct {
+	Healthy    int              `json:"healthy"`
+	Connected  int              `json:"connected"`
+	Total      int              `json:"total"`
+	Bad        int              `json:"bad"`
+	Conflicts  int              `json:"conflicts"`
+	Conflicted int              `json:"conflicted"`
+	Syncing    bool             `json:"syncing"`
+	Forwards   int              `json:"forwards"`
+	Sessions   []SessionSummary `json:"sessions,omitempty"`
+}
+
+type SessionSummary struct {
+	Identifier string `json:"identifier"`
+	Name       string `json:"name,omitempty"`
+	Alpha      string `json:"alpha"`
+	Beta       string `json:"beta"`
+	Status     string `json:"status"`
+	Health     string `json:"health"`
+	Conflicts  int    `json:"conflicts"`
+	LastError  string `json:"lastError,omitempty"`
+}
+
+func isForwardBad(state *forwarding.State) bool {
+	return forwardHalted(state) || !isForward(state, forwardConnected)
+}
+
+// Summarize counts synchronization sessions the same way the tray title does
+func Summarize(states map[string]*synchronization.State, classes Classification) Summary {
+	var summary Summary
+	for _, state := range states {
+		summary.Total++
+		if classes.Is(state, CategorySyncing) {
+			summary.Syncing = true
+		}
+		if classes.Bad(state) {
+			summary.Bad++
+		} else if hasConflicts(state) {
+			summary.Conflicted++
+		}
+		if hasConflicts(state) {
+			summary.Conflicts++
+		}
+	}
+	summary.update()
+	return summary
+}
+
+// AddForwards counts forwarding sessions in, they never have conflicts
+func (self *Summary) AddForwards(states map[string]*forwarding.State) {
+	for _, state := range states {
+		self.Total++
+		self.Forwards++
+		if isForwardBad(state) {
+			self.Bad++
+		}
+	}
+	self.update()
+}
+
+func (self *Summary) update() {
+	self.Healthy = self.Total - self.Conflicted - self.Bad
+	self.Connected = self.Total - self.Bad
+}
+
+// Render executes a title or tooltip template with summary
+func (self Summary) Render(tmpl *template.Template) (string, error) {
+	var out strings.Builder
+	err := tmpl.Execute(&out, self)
+	if err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+// SessionDetails describes each synchronization session, ordered by identifier
+func SessionDetails(states map[string]*synchronization.State, classes Classification) []SessionSummary {
+	details := make([]SessionSummary, 0, len(states))
+	for _, state := range states {
+		health := healthOf(state, classes).category
+		if health == "" {
+			health = "unknown"
+		}
+		details = append(details, SessionSummary{
+			Identifier: state.Session.Identifier,
+			Name:       state.Session.Name,
+			Alpha:      state.Session.Alpha.Format(""),
+			Beta:       state.Session.Beta.Format(""),
+			Status:     statusName(state.Status),
+			Health:     health,
+			Conflicts:  len(state.Conflicts),
+			LastError:  state.LastError,
+		})
+	}
+	sort.Slice(details, func(i, j int) bool {
+		return details[i].Identifier < details[j].Identifier
+	})
+	return details
+}
+
+// Status summarizes current synchronization and forwarding sessions
+func (self *MutagenMon) Status(ctx context.Context) (Summary, error) {
+	states, err := self.SessionStates(ctx)
+	if err != nil {
+		return Summary{}, err
+	}
+	_, forwards, err := self.WaitForwardStates(ctx, 0)
+	if err != nil {
+		return Summary{}, err
+	}
+	config := self.Config()
+	summary := Summarize(shown(states, config), config.Classification)
+	summary.AddForwards(shownForwards(forwards, config))
+	summary.Sessions = SessionDetails(shown(states, config), config.Classification)
+	return summary, nil
+}
@@ -0,0 +1,36 @@
+package mutagenmon
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name   string
+		states []*synchronization.State
+		want   Summary
+	}{
+		{"healthy", []*synchronization.State{
+			testState("a", synchronization.Status_Watching),
+		}, Summary{Healthy: 1, Connected: 1, Total: 1}},
+		{"conflict", []*synchronization.State{
+			testState("a", synchronization.Status_Watching, "x"),
+			testState("b", synchronization.Status_Watching),
+		}, Summary{Healthy: 1, Connected: 2, Total: 2, Conflicts: 1, Conflicted: 1}},
+		{"halted with conflicts", []*synchronization.State{
+			testState("a", synchronization.Status_HaltedOnRootDeletion, "x"),
+		}, Summary{Total: 1, Bad: 1, Conflicts: 1}},
+	}
+	for _, test := range tests {
+		got := Summarize(testStates(test.states...), DefaultClassification())
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+		if got.Healthy+got.Conflicted+got.Bad != got.Total {
+			t.Errorf("%s: counts don't add up to total: %+v", test.name, got)
+		}
+	}
+}
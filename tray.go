@@ -0,0 +1,75 @@
+package mutagenmon
+
+import (
+	"fyne.io/systray"
+)
+
+// Tray is the system tray the monitor draws on
+type Tray interface {
+	AddMenuItem(title string, tooltip string) MenuItem
+	AddSeparator()
+	SetIcon(icon []byte)
+	SetTitle(title string)
+	SetTooltip(tooltip string)
+	Quit()
+}
+
+// MenuItem is an entry of the tray menu or of a submenu
+type MenuItem interface {
+	AddSubMenuItem(title string, tooltip string) MenuItem
+	AddSubMenuItemCheckbox(title string, tooltip string, checked bool) MenuItem
+	SetTitle(title string)
+	SetTooltip(tooltip string)
+	SetIcon(icon []byte)
+	Show()
+	Hide()
+	Enable()
+	Disable()
+	Check()
+	Uncheck()
+	// Clicked receives a value every time the item is clicked
+	Clicked() <-chan struct{}
+}
+
+// SystrayTray draws on the real system tray, it is usable after systray.Run calls onReady
+type SystrayTray struct{}
+
+func (SystrayTray) AddMenuItem(title string, tooltip string) MenuItem {
+	return systrayItem{systray.AddMenuItem(title, tooltip)}
+}
+
+func (SystrayTray) AddSeparator() {
+	systray.AddSeparator()
+}
+
+func (SystrayTray) SetIcon(icon []byte) {
+	systray.SetIcon(icon)
+}
+
+func (SystrayTray) SetTitle(title string) {
+	systray.SetTitle(title)
+}
+
+func (SystrayTray) SetTooltip(tooltip string) {
+	systray.SetTooltip(tooltip)
+}
+
+func (SystrayTray) Quit() {
+	systray.Quit()
+}
+
+type systrayItem struct {
+	*systray.MenuItem
+}
+
+func (self systrayItem) AddSubMenuItem(title string, tooltip string) MenuItem {
+	return systrayItem{self.MenuItem.AddSubMenuItem(title, tooltip)}
+}
+
+func (self systrayItem) AddSubMenuItemCheckbox(title string, tooltip string, checked bool) MenuItem {
+	return systrayItem{self.MenuItem.AddSubMenuItemCheckbox(title, tooltip, checked)}
+}
+
+func (self systrayItem) Clicked() <-chan struct{} {
+	return self.ClickedCh
+}
@@ -0,0 +1,104 @@
+package mutagenmon
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+)
+
+const (
+	TrayIconRing   = "ring"
+	TrayIconStatic = "static"
+)
+
+const trayIconSize = 64
+
+var (
+	healthyColor  = color.RGBA{0x34, 0xc7, 0x59, 0xff}
+	conflictColor = color.RGBA{0xff, 0x95, 0x00, 0xff}
+	badColor      = color.RGBA{0xff, 0x3b, 0x30, 0xff}
+	emptyColor    = color.RGBA{0x8e, 0x8e, 0x93, 0xff}
+	syncingColor  = color.RGBA{0x0a, 0x84, 0xff, 0xff}
+)
+
+// RenderTrayIcon draws a ring split into healthy, conflict and bad segments clockwise from the top,
+// with a dot in the middle while something is syncing
+func RenderTrayIcon(summary Summary) ([]byte, error) {
+	healthy, conflicts, bad := summary.Healthy, summary.Conflicted, summary.Bad
+	total := float64(healthy + conflicts + bad)
+
+	img := image.NewRGBA(image.Rect(0, 0, trayIconSize, trayIconSize))
+	center := float64(trayIconSize) / 2
+	outer := center - 1
+	inner := outer * 0.6
+	dot := outer * 0.3
+	for y := 0; y < trayIconSize; y++ {
+		for x := 0; x < trayIconSize; x++ {
+			dx := float64(x) + 0.5 - center
+			dy := float64(y) + 0.5 - center
+			distance := math.Hypot(dx, dy)
+			if summary.Syncing && distance <= dot {
+				img.Set(x, y, syncingColor)
+				continue
+			}
+			if distance < inner || distance > outer {
+				continue
+			}
+			if total == 0 {
+				img.Set(x, y, emptyColor)
+				continue
+			}
+			// fraction of the full turn, clockwise from 12 o'clock
+			turn := (math.Atan2(dx, -dy) + math.Pi) / (2 * math.Pi)
+			turn = math.Mod(turn+0.5, 1)
+			switch {
+			case turn < float64(healthy)/total:
+				img.Set(x, y, healthyColor)
+			case turn < float64(healthy+conflicts)/total:
+				img.Set(x, y, conflictColor)
+			default:
+				img.Set(x, y, badColor)
+			}
+		}
+	}
+	var out bytes.Buffer
+	err := png.Encode(&out, img)
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// updateTrayIcon redraws the tray icon if counts changed, titleLock must be held
+func (self *MutagenMon) updateTrayIcon(summary Summary, config Config) {
+	key := TrayIconStatic
+	if config.TrayIcon == TrayIconRing {
+		key = fmt.Sprintf("%d/%d/%d/%v", summary.Healthy, summary.Conflicted, summary.Bad, summary.Syncing)
+	}
+	if key == self.trayIcon {
+		return
+	}
+	self.trayIcon = key
+	if config.TrayIcon != TrayIconRing {
+		self.tray.SetIcon(Icon("icon.png"))
+		return
+	}
+	icon, err := RenderTrayIcon(summary)
+	if err != nil {
+		log.Printf("[WARN] render tray icon: %s", err)
+		return
+	}
+	self.tray.SetIcon(icon)
+}
+
+// RedrawTrayIcon forces the tray icon to be rendered again, e.g. after icon files changed
+func (self *MutagenMon) RedrawTrayIcon() {
+	self.titleLock.Lock()
+	self.trayIcon = ""
+	self.titleLock.Unlock()
+	self.UpdateTitle()
+}
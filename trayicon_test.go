@@ -0,0 +1,48 @@
+package mutagenmon
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestRenderTrayIcon(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary Summary
+		top     color.Color // just right of 12 o'clock on the ring
+		bottom  color.Color // just left of 6 o'clock on the ring
+		center  color.Color
+	}{
+		{"empty", Summary{}, emptyColor, emptyColor, color.RGBA{}},
+		{"healthy", Summary{Healthy: 2, Connected: 2, Total: 2}, healthyColor, healthyColor, color.RGBA{}},
+		{"half bad", Summary{Healthy: 1, Connected: 1, Total: 2, Bad: 1}, healthyColor, badColor, color.RGBA{}},
+		{"conflict", Summary{Healthy: 0, Connected: 1, Total: 1, Conflicts: 1, Conflicted: 1, Syncing: true}, conflictColor, conflictColor, syncingColor},
+		{"halted with conflicts", Summary{Total: 1, Bad: 1, Conflicts: 1}, badColor, badColor, color.RGBA{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := RenderTrayIcon(test.summary)
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if img.Bounds().Dx() != trayIconSize {
+				t.Errorf("size %d", img.Bounds().Dx())
+			}
+			check := func(where string, x, y int, want color.Color) {
+				got := color.RGBAModel.Convert(img.At(x, y))
+				if got != color.RGBAModel.Convert(want) {
+					t.Errorf("%s: got %v, want %v", where, got, want)
+				}
+			}
+			check("top", trayIconSize/2+1, 4, test.top)
+			check("bottom", trayIconSize/2-2, trayIconSize-5, test.bottom)
+			check("center", trayIconSize/2, trayIconSize/2, test.center)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package mutagenmon
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/mutagen-io/mutagen/pkg/selection"
+	"github.com/mutagen-io/mutagen/pkg/synchronization"
+)
+
+func isStaging(state *synchronization.State) bool {
+	return state.GetAlphaState().GetStagingProgress() != nil || state.GetBetaState().GetStagingProgress() != nil
+}
+
+// synced tells if a session has nothing left to do, it fails for sessions that will not get there by themselves
+func synced(state *synchronization.State, classes Classification) (bool, error) {
+	if classes.Bad(state) {
+		return false, fmt.Errorf("session %s is %s", sessionTitle(state), state.Status.Description())
+	}
+	if hasConflicts(state) {
+		return false, fmt.Errorf("session %s has %d conflicts", sessionTitle(state), len(state.Conflicts))
+	}
+	return classes.Is(state, CategoryWatching) && !isStaging(state), nil
+}
+
+// WaitSynced flushes selected sessions and blocks until all of them are watching with nothing staged
+func (self *MutagenMon) WaitSynced(ctx context.Context, sessions *selection.Selection) error {
+	err := sessions.EnsureValid()
+	if err != nil {
+		return fmt.Errorf("invalid selection: %v", err)
+	}
+	err = self.Act(ctx, ActionFlush, sessions)
+	if err != nil {
+		return err
+	}
+	classes := self.Config().Classification
+	var index uint64
+	for {
+		var states map[string]*synchronization.State
+		index, states, err = self.WaitSelectedStates(ctx, sessions, index)
+		if err != nil {
+			return err
+		}
+		if len(states) == 0 {
+			return fmt.Errorf("no sessions selected")
+		}
+		done := true
+		for _, state := range states {
+			ok, err := synced(state, classes)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				log.Printf("[DEBUG] waiting for %s: %s", sessionTitle(state), state.Status.Description())
+				done = false
+			}
+		}
+		if done {
+			return nil
+		}
+	}
+}